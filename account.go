@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+
+	client "report-stat/httpclient"
+	"report-stat/notify"
+	"report-stat/schedule"
+)
+
+var (
+	// ErrNoAccount account file contains no account
+	ErrNoAccount = errors.New("account: no account")
+	// ErrDuplicateName the names of accounts are duplicated
+	ErrDuplicateName = errors.New("account: duplicate name")
+	// ErrAccountNotFound account with the name is not found
+	ErrAccountNotFound = errors.New("account: not found")
+)
+
+// accountConfig config of an account
+type accountConfig struct {
+	client.Account
+	PasswordFile string          `json:"passwordFile"` // file containing the password, must not be accessible by others
+	Name         string          `json:"name"`         // name used in logs and server path, default: key
+	TimeTable    *schedule.Table `json:"timeTable"`    // default: the time table loaded from file
+	Notifiers    []notify.Config `json:"notifiers"`    // default: email
+	Contacts     contacts        `json:"contacts"`     // class name -> contacts, e.g. class monitors
+}
+
+// contact the contact who receives the reminder of a class
+type contact struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+type contacts map[string][]contact
+
+// emails return the email addresses of the class
+func (c contacts) emails(class string) []string {
+	var res []string
+	for _, v := range c[class] {
+		if v.Email != "" {
+			res = append(res, v.Email)
+		}
+	}
+	return res
+}
+
+type accountList []*accountConfig
+
+// UnmarshalJSON accept both a single account and a list of accounts
+func (list *accountList) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) != 0 && data[0] == '[' {
+		return unmarshalJson(data, (*[]*accountConfig)(list))
+	}
+	account := &accountConfig{}
+	if err := unmarshalJson(data, account); err != nil {
+		return err
+	}
+	*list = accountList{account}
+	return nil
+}
+
+// loadAccounts load the accounts from the config file or the file with name,
+// and fill the default values
+func loadAccounts(name string) (accountList, error) {
+	var list accountList
+	if config != nil && config.Accounts != nil {
+		list = config.Accounts
+	} else if err := loadJson(&list, name); err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, ErrNoAccount
+	}
+	names := make(map[string]struct{}, len(list))
+	for _, account := range list {
+		if account.Name == "" {
+			account.Name = account.Key
+		}
+		if account.Name == "" {
+			account.Name = account.Username
+		}
+		if _, ok := names[account.Name]; ok {
+			return nil, ErrDuplicateName
+		}
+		names[account.Name] = struct{}{}
+		sort.Strings(account.Class)
+	}
+	return list, nil
+}
+
+// find return the account with the name, empty name means the first account
+func (list accountList) find(name string) (*accountConfig, error) {
+	if name == "" {
+		return list[0], nil
+	}
+	for _, account := range list {
+		if account.Name == name {
+			return account, nil
+		}
+	}
+	return nil, ErrAccountNotFound
+}
+
+// filter return the account with the name, empty name means all the accounts
+func (list accountList) filter(name string) (accountList, error) {
+	if name == "" {
+		return list, nil
+	}
+	account, err := list.find(name)
+	if err != nil {
+		return nil, err
+	}
+	return accountList{account}, nil
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"report-stat/logging"
+)
+
+// command a subcommand of the cli
+type command struct {
+	name    string // e.g. "once", "config validate"
+	args    string // synopsis of the arguments after the flags
+	summary string
+	run     func(flagSet *flag.FlagSet, args []string) int // parse the flags and run, return the exit code
+}
+
+var commands []*command
+
+func init() {
+	commands = []*command{
+		{name: "run", summary: "run the scheduler as a daemon, the default command", run: runCommand},
+		{name: "once", summary: "run a single fetch/render/notify cycle and exit\n\n" + onceExitCodes, run: onceCommand},
+		{name: "login-check", summary: "check the username and password of the accounts", run: loginCheckCommand},
+		{name: "forms", summary: "print the students who have not reported", run: formsCommand},
+		{name: "render", summary: "render the images from the saved data.json without fetching", run: renderCommand},
+		{name: "encrypt-password", summary: "encrypt the password read from the terminal or stdin with the key file, the key file is created if not exists", run: encryptPasswordCommand},
+		{name: "config validate", summary: "validate the config files", run: configValidateCommand},
+		{name: "status", summary: "print the next slot of the accounts and the holiday the scheduler is paused by", run: statusCommand},
+		{name: "stats", summary: "print the statistics of the students from the history", run: statsCommand},
+		{name: "schedule preview", summary: "preview the slots with a virtual clock", run: previewCommand},
+		{name: "help", args: "[command]", summary: "print the help of the command", run: helpCommand},
+	}
+}
+
+// configFlags register the flags of the config files shared by all the commands,
+// the current values are used as the defaults, so that the flags before the
+// command are kept
+func configFlags(flagSet *flag.FlagSet) {
+	flagSet.StringVar(&accountPath, "a", accountPath, "set account file path")
+	flagSet.StringVar(&emailCfgPath, "e", emailCfgPath, "set email file path")
+	flagSet.StringVar(&timeTablePath, "t", timeTablePath, "set time table file path")
+	flagSet.StringVar(&keyPath, "key", keyPath, "set the key `file` to decrypt the passwords")
+	flagSet.StringVar(&historyDir, "history", historyDir, "set history `directory`, empty to disable")
+	flagSet.UintVar(&retention, "retention", retention, "set the `days` to keep the history, 0 to keep forever")
+	flagSet.StringVar(&calendarPath, "calendar", calendarPath, "set the school calendar `file`(.ics), the slots in the holidays are suspended")
+	flagSet.Func("log-level", "set the log `level`: debug, info, warn or error (default: info)", func(s string) error {
+		var level logging.Level
+		if err := level.UnmarshalText([]byte(s)); err != nil {
+			return err
+		}
+		logger.SetLevel(level)
+		return nil
+	})
+	flagSet.Func("log-format", "set the log `format`: text or json (default: text)", func(s string) error {
+		var format logging.Format
+		if err := format.UnmarshalText([]byte(s)); err != nil {
+			return err
+		}
+		logger.SetFormat(format)
+		return nil
+	})
+	flagSet.Func("holiday", "set the `tags` of the holiday events, separated by comma(default: holiday,假期,寒假,暑假)", func(s string) error {
+		holidayTags = strings.Split(s, ",")
+		return nil
+	})
+}
+
+// runFlags register the flags of the run command
+func runFlags(flagSet *flag.FlagSet) {
+	flagSet.UintVar(&maxAttempts, "c", maxAttempts, "set max attepmts")
+	flagSet.StringVar(&listenAddr, "listen", listenAddr, "serve the web page and data on the `address`, e.g. :8080")
+	flagSet.StringVar(&statePath, "state", statePath, "set the state `file` to record the last slot run, empty to disable")
+	flagSet.DurationVar(&catchUpWindow, "catchup", catchUpWindow, "run the slot missed within the `duration` once on startup or resume, 0 to disable")
+	flagSet.Func("token", "set the bearer `token` of POST /api/refresh, empty to disable the api (default: $REPORT_STAT_TOKEN)", func(s string) error {
+		refreshToken = s
+		return nil
+	})
+	flagSet.DurationVar(&shutdownGrace, "grace", shutdownGrace, "wait at most the `duration` for the running tasks on exit")
+	flagSet.DurationVar(&staleAfter, "stale", staleAfter, "report unhealthy on /healthz if an account has no successful task within the `duration`, 0 to disable")
+	flagSet.DurationVar(&watchInterval, "watch", watchInterval, "poll the config files every `interval` and reload the app on change, 0 to disable")
+}
+
+// setFlags the names of the flags set on the command line
+var setFlags = make(map[string]bool)
+
+// visitFlags record the flags set on the command line of flagSet
+func visitFlags(flagSet *flag.FlagSet) {
+	flagSet.Visit(func(f *flag.Flag) {
+		setFlags[f.Name] = true
+	})
+}
+
+// dispatch parse the global flags and run the command, return the exit code.
+// The global flags are the flags of the config files and the run command,
+// the run command is used if no command is given.
+func dispatch(args []string) int {
+	flagSet := flag.NewFlagSet("report-stat", flag.ExitOnError)
+	flagSet.Usage = func() {
+		out := flagSet.Output()
+		fmt.Fprint(out, "usage: report-stat [flags] [command] [command flags] [arguments]\n\ncommands:\n")
+		for _, cmd := range commands {
+			fmt.Fprintf(out, "  %-18s %s\n", cmd.name, strings.SplitN(cmd.summary, "\n", 2)[0])
+		}
+		fmt.Fprint(out, "\nRun 'report-stat help <command>' for the flags of the command.\n\nflags:\n")
+		flagSet.PrintDefaults()
+	}
+	flagSet.StringVar(&configPath, "config", configPath, "set the config `file`(.json, .yaml), the missing sections are loaded from -a, -e and -t (default: $REPORT_STAT_CONFIG)")
+	configFlags(flagSet)
+	runFlags(flagSet)
+	flagSet.Parse(args)
+	visitFlags(flagSet)
+	if configPath != "" { // the flags take precedence over the config file
+		var err error
+		if config, err = loadConfig(configPath); err != nil {
+			logger.Error(err.Error())
+			return 1
+		}
+		config.apply()
+		flagSet.Parse(args)
+	}
+
+	args = flagSet.Args()
+	if len(args) == 0 {
+		return runCommand(newFlagSet(commands[0]), nil)
+	}
+	cmd, n := findCommand(args)
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n", strings.Join(args[:n], " "))
+		flagSet.Usage()
+		return 2
+	}
+	return cmd.run(newFlagSet(cmd), args[n:])
+}
+
+// findCommand return the command and the number of the words in args it matches,
+// cmd is nil if the command is not found
+func findCommand(args []string) (cmd *command, n int) {
+	n = 1
+	for _, c := range commands {
+		words := strings.Fields(c.name)
+		if len(words) > len(args) {
+			if words[0] == args[0] {
+				n = len(args) // the subcommand is missing, e.g. "config"
+			}
+			continue
+		}
+		if strings.Join(args[:len(words)], " ") == c.name {
+			return c, len(words)
+		}
+	}
+	return nil, n
+}
+
+// newFlagSet return the flag set of the command with the config flags
+func newFlagSet(cmd *command) *flag.FlagSet {
+	flagSet := flag.NewFlagSet(cmd.name, flag.ExitOnError)
+	flagSet.Usage = func() {
+		out := flagSet.Output()
+		fmt.Fprintf(out, "usage: report-stat %s [flags] %s\n\n%s\n\nflags:\n", cmd.name, cmd.args, cmd.summary)
+		flagSet.PrintDefaults()
+	}
+	configFlags(flagSet)
+	return flagSet
+}
+
+// helpCommand print the help of the command
+func helpCommand(flagSet *flag.FlagSet, args []string) int {
+	flagSet.Parse(args)
+	if flagSet.NArg() == 0 {
+		return dispatch([]string{"-h"})
+	}
+	cmd, n := findCommand(flagSet.Args())
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n", strings.Join(flagSet.Args()[:n], " "))
+		return 2
+	}
+	fs := newFlagSet(cmd)
+	fs.SetOutput(os.Stdout)
+	cmd.run(fs, []string{"-h"})
+	return 0
+}
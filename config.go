@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	client "report-stat/httpclient"
+	"report-stat/notify"
+	"report-stat/schedule"
+	"report-stat/secret"
+
+	"github.com/yin1999/healthreport/utils/email"
+)
+
+// strictJSON reject the unknown fields in the config files
+var strictJSON bool
+
+// validator collect the problems of the config
+type validator struct {
+	errors, warnings int
+}
+
+func (v *validator) error(format string, a ...interface{}) {
+	v.errors++
+	printf("error:   "+format+"\n", a...)
+}
+
+func (v *validator) warn(format string, a ...interface{}) {
+	v.warnings++
+	printf("warning: "+format+"\n", a...)
+}
+
+// configValidateCommand check the config files, return the exit code.
+// The json is decoded strictly, the time tables, the output paths, the font
+// and the SMTP server are checked, the classes are compared with the live
+// form if -live is set.
+func configValidateCommand(flagSet *flag.FlagSet, args []string) int {
+	live := flagSet.Bool("live", false, "login and compare the configured classes with the classes of the form today")
+	smtpCheck := flagSet.Bool("smtp", true, "login to the SMTP server")
+	flagSet.Parse(args)
+
+	strictJSON, schedule.Strict = true, true
+	v := &validator{}
+
+	if configPath != "" {
+		var err error
+		if config, err = loadConfig(configPath); err != nil {
+			v.error("%s", err.Error())
+			return v.done()
+		}
+	}
+	if config != nil && config.Email != nil {
+		emailCfg = config.Email
+	} else if _, err := os.Stat(emailCfgPath); err == nil {
+		cfg := &email.Config{}
+		if err = loadJson(cfg, emailCfgPath); err != nil {
+			v.error("%s", err.Error())
+		} else if emailCfg, err = email.LoadConfig(emailCfgPath); err != nil {
+			v.error("%s: %s", emailCfgPath, err.Error())
+		}
+	} else {
+		v.warn("%s: email is not enabled: %s", emailCfgPath, err.Error())
+	}
+	if emailCfg != nil {
+		logRedactor.add(emailCfg.SMTP.Password)
+	}
+	if emailCfg != nil && *smtpCheck {
+		if err := emailCfg.LoginTest(); err != nil {
+			v.error("email: SMTP login: %s", err.Error())
+		}
+	}
+
+	accounts, err := loadSchedules()
+	if err != nil {
+		v.error("%s", err.Error())
+		return v.done()
+	}
+	if err = client.LoadFont(); err != nil {
+		v.error("font: %s", err.Error())
+	}
+	if historyDir != "" {
+		if err = checkWritable(historyDir); err != nil {
+			v.error("history: %s", err.Error())
+		}
+	}
+	if statePath != "" {
+		if err = checkWritable(filepath.Dir(statePath)); err != nil {
+			v.error("state: %s", err.Error())
+		}
+	}
+
+	checked := make(map[*schedule.Table]bool)
+	now := time.Now()
+	for _, account := range accounts {
+		v.account(account, checked, now)
+		if *live {
+			v.live(account)
+		}
+	}
+	return v.done()
+}
+
+// account check the config of the account
+func (v *validator) account(account *accountConfig, checked map[*schedule.Table]bool, now time.Time) {
+	name := account.Name
+	if account.Username == "" || account.Password == "" && account.PasswordFile == "" {
+		v.error("account %s: username or password is empty", name)
+	} else if _, err := account.password(); err != nil {
+		v.error("account %s: password: %s", name, err.Error())
+	} else if !secret.Encrypted(account.Password) && account.PasswordFile == "" {
+		v.warn("account %s: the password is stored in plain text, see encrypt-password", name)
+	}
+	if account.Wid == "" || account.Key == "" {
+		v.error("account %s: wid or key is empty", name)
+	}
+	if len(account.Class) == 0 {
+		v.warn("account %s: no class, no image is generated", name)
+	}
+	if account.File != "" {
+		if err := checkWritable(filepath.Dir(account.File)); err != nil {
+			v.error("account %s: file: %s", name, err.Error())
+		}
+	}
+	if account.Out != "" {
+		if err := checkWritable(account.Out); err != nil {
+			v.error("account %s: out: %s", name, err.Error())
+		}
+	}
+	for i := range account.Notifiers {
+		if _, err := notify.New(&account.Notifiers[i], emailCfg); err != nil {
+			v.error("account %s: notifier %d: %s", name, i, err.Error())
+		}
+	}
+	if len(account.Contacts) != 0 && emailCfg == nil {
+		v.warn("account %s: email is not enabled, the contacts are ignored", name)
+	}
+	for class := range account.Contacts {
+		if !contains(account.Class, class) {
+			v.warn("account %s: contacts of class %s which is not configured", name, class)
+		}
+	}
+
+	table := account.TimeTable
+	if checked[table] {
+		return
+	}
+	checked[table] = true
+	for _, err := range table.Check() {
+		v.warn("account %s: time table: %s", name, err.Error())
+	}
+	for _, err := range table.Calendar.Check() {
+		v.warn("account %s: %s", name, err.Error())
+	}
+	if table.Next(now).Time.IsZero() {
+		v.warn("account %s: time table: no slot in the next year", name)
+	}
+}
+
+// live login and compare the configured classes with the form today
+func (v *validator) live(account *accountConfig) {
+	plain, err := account.plain()
+	if err != nil {
+		return // reported by account
+	}
+	ctx, cc := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cc()
+	form, err := client.GetForm(ctx, plain, time.Now().In(timeZone).Format("2006-01-02"))
+	if err != nil {
+		if errors.Is(err, client.ErrCouldNotLogin) {
+			v.error("account %s: %s", account.Name, err.Error())
+		} else {
+			v.error("account %s: get form: %s", account.Name, err.Error())
+		}
+		return
+	}
+	for _, class := range account.Class {
+		if class != "全部" && !contains(form.Classes, class) {
+			v.warn("account %s: class %s is not in the form today (or all the students have reported)", account.Name, class)
+		}
+	}
+	for _, class := range form.Classes {
+		if !contains(account.Class, class) && !contains(account.Class, "全部") {
+			v.warn("account %s: class %s of the form is not configured", account.Name, class)
+		}
+	}
+}
+
+// done print the summary and return the exit code
+func (v *validator) done() int {
+	fmt.Printf("%d errors, %d warnings\n", v.errors, v.warnings)
+	if v.errors != 0 {
+		return exitError
+	}
+	return exitOK
+}
+
+// checkWritable check whether the files can be created in dir,
+// the nearest existing parent is checked if dir does not exist
+func checkWritable(dir string) error {
+	for {
+		info, err := os.Stat(dir)
+		if err == nil {
+			if !info.IsDir() {
+				return fmt.Errorf("%s is not a directory", dir)
+			}
+			break
+		}
+		if !errors.Is(err, os.ErrNotExist) || filepath.Dir(dir) == dir {
+			return err
+		}
+		dir = filepath.Dir(dir)
+	}
+	f, err := os.CreateTemp(dir, ".report-stat-*")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// contains report whether list contains s
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,439 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	client "report-stat/httpclient"
+	"report-stat/logging"
+	"report-stat/schedule"
+
+	"github.com/yin1999/healthreport/utils/email"
+	"gopkg.in/yaml.v3"
+)
+
+// envPrefix the prefix of the environment variables overriding the config file
+const envPrefix = "REPORT_STAT_"
+
+// configFile the unified config file in json or yaml format.
+// The missing sections of accounts, email and time table are loaded from the
+// legacy files, the other settings are the defaults of the flags.
+type configFile struct {
+	Accounts  []*accountConfig `json:"accounts"`
+	Email     *email.Config    `json:"email"`
+	TimeTable *schedule.Table  `json:"timeTable"`
+	Schedule  struct {
+		MaxAttempts *uint              `json:"maxAttempts" flag:"c"`
+		Retry       *retryPolicy       `json:"retry"`
+		CatchUp     *schedule.Duration `json:"catchUp" flag:"catchup"`
+		State       *string            `json:"state" flag:"state"`
+		Calendar    *string            `json:"calendar" flag:"calendar"`
+		HolidayTags []string           `json:"holidayTags" flag:"holiday"`
+		Watch       *schedule.Duration `json:"watch" flag:"watch"`
+	} `json:"schedule"`
+	Server struct {
+		Listen *string            `json:"listen" flag:"listen"`
+		Token  *string            `json:"token" flag:"token"`
+		Grace  *schedule.Duration `json:"grace" flag:"grace"`
+		Stale  *schedule.Duration `json:"stale" flag:"stale"`
+	} `json:"server"`
+	History struct {
+		Dir       *string `json:"dir" flag:"history"`
+		Retention *uint   `json:"retention" flag:"retention"`
+	} `json:"history"`
+	Render struct {
+		Font *string `json:"font"`
+	} `json:"render"`
+	Log struct {
+		Level  *logging.Level  `json:"level" flag:"log-level"`
+		Format *logging.Format `json:"format" flag:"log-format"`
+	} `json:"log"`
+}
+
+// config the loaded config file, nil if not used
+var config *configFile
+
+// loadConfig load the config file, .yaml and .yml files are decoded as yaml,
+// the others as json. The fields are overridden by the environment variables,
+// e.g. REPORT_STAT_SERVER_LISTEN, REPORT_STAT_ACCOUNTS_0_PASSWORD, and the
+// variables ending with _FILE are the paths of the files containing the values,
+// e.g. REPORT_STAT_EMAIL_SMTP_PASSWORD_FILE=/run/secrets/smtp.
+func loadConfig(name string) (*configFile, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var tree interface{}
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &tree)
+	default:
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		err = dec.Decode(&tree)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	if tree == nil { // empty file
+		tree = map[string]interface{}{}
+	}
+	if m, ok := tree.(map[string]interface{}); ok {
+		if err = mergeLegacy(m, os.Environ()); err != nil {
+			return nil, err
+		}
+	}
+	cfg := &configFile{}
+	policy := retry // the missing fields are the defaults
+	cfg.Schedule.Retry = &policy
+	var unknown []string
+	if tree, unknown, err = applyEnv(tree, reflect.TypeOf(cfg), os.Environ()); err != nil {
+		return nil, err
+	}
+	for _, name := range unknown {
+		logger.Warn("Environment variable matches no config field", "name", name)
+	}
+	if data, err = json.Marshal(tree); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	if err = unmarshalJson(data, cfg); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	if v := cfg.Schedule.Retry; v != nil {
+		if err = v.check(); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return cfg, nil
+}
+
+// apply set the flags with the settings of the config file
+func (cfg *configFile) apply() {
+	if v := cfg.Schedule.MaxAttempts; v != nil {
+		maxAttempts = *v
+	}
+	if v := cfg.Schedule.Retry; v != nil {
+		retry = *v
+	}
+	if v := cfg.Schedule.CatchUp; v != nil {
+		catchUpWindow = time.Duration(*v)
+	}
+	if v := cfg.Schedule.State; v != nil {
+		statePath = *v
+	}
+	if v := cfg.Schedule.Calendar; v != nil {
+		calendarPath = *v
+	}
+	if v := cfg.Schedule.HolidayTags; v != nil {
+		holidayTags = v
+	}
+	if v := cfg.Schedule.Watch; v != nil {
+		watchInterval = time.Duration(*v)
+	}
+	if v := cfg.Server.Listen; v != nil {
+		listenAddr = *v
+	}
+	if v := cfg.Server.Token; v != nil {
+		refreshToken = *v
+	}
+	if v := cfg.Server.Grace; v != nil {
+		shutdownGrace = time.Duration(*v)
+	}
+	if v := cfg.Server.Stale; v != nil {
+		staleAfter = time.Duration(*v)
+	}
+	if v := cfg.History.Dir; v != nil {
+		historyDir = *v
+	}
+	if v := cfg.History.Retention; v != nil {
+		retention = *v
+	}
+	if v := cfg.Render.Font; v != nil {
+		client.FontFile = *v
+	}
+	if v := cfg.Log.Level; v != nil {
+		logger.SetLevel(*v)
+	}
+	if v := cfg.Log.Format; v != nil {
+		logger.SetFormat(*v)
+	}
+}
+
+// legacySections the sections loaded from the legacy files if missing
+var legacySections = []struct {
+	key, env string
+	path     *string
+}{
+	{"accounts", "ACCOUNTS_", &accountPath},
+	{"email", "EMAIL_", &emailCfgPath},
+	{"timeTable", "TIME_TABLE_", &timeTablePath},
+}
+
+// mergeLegacy copy the legacy file of the missing section into tree if the
+// section is overridden by the environment variables, so that the overrides
+// apply to the legacy file instead of replacing it with a stub section
+func mergeLegacy(tree map[string]interface{}, environ []string) error {
+	for _, section := range legacySections {
+		if _, ok := tree[section.key]; ok || !hasEnv(environ, envPrefix+section.env) {
+			continue
+		}
+		data, err := os.ReadFile(*section.path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		var v interface{}
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		if err = dec.Decode(&v); err != nil {
+			return fmt.Errorf("%s: %w", *section.path, err)
+		}
+		if _, ok := v.(map[string]interface{}); ok && section.key == "accounts" { // a single account
+			v = []interface{}{v}
+		}
+		tree[section.key] = v
+	}
+	return nil
+}
+
+func hasEnv(environ []string, prefix string) bool {
+	for _, kv := range environ {
+		if strings.HasPrefix(kv, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// envFlags the environment variables with envPrefix which are not config fields
+var envFlags = map[string]bool{"CONFIG": true, "TOKEN": true}
+
+// applyEnv override the values of tree, the decoded config of type t, with the
+// environment variables with envPrefix, and return the names of the variables
+// matching no field, e.g. the typo REPORT_STAT_SEVER_LISTEN
+func applyEnv(tree interface{}, t reflect.Type, environ []string) (_ interface{}, unknown []string, err error) {
+	for _, kv := range environ {
+		if !strings.HasPrefix(kv, envPrefix) {
+			continue
+		}
+		key, value, _ := strings.Cut(kv[len(envPrefix):], "=")
+		if envFlags[key] {
+			continue
+		}
+		name := envPrefix + key
+		if strings.HasSuffix(key, "_FILE") && !hasField(t, strings.Split(key, "_")) {
+			data, err := os.ReadFile(value)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s: %w", name, err)
+			}
+			key, value = strings.TrimSuffix(key, "_FILE"), strings.TrimRight(string(data), "\r\n")
+		}
+		if v, ok := setPath(tree, t, strings.Split(key, "_"), value); ok {
+			tree = v
+		} else {
+			unknown = append(unknown, name)
+		}
+	}
+	return tree, unknown, nil
+}
+
+// setPath set the value at the path of the upper snake case words in tree,
+// t is the type of tree, nil if unknown. It returns false if the path is
+// not a field of t.
+func setPath(tree interface{}, t reflect.Type, path []string, value string) (interface{}, bool) {
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if len(path) == 0 {
+		return parseValue(value, t), true
+	}
+	if t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) || t == nil && isSlice(tree) {
+		i, err := strconv.Atoi(path[0])
+		list, _ := tree.([]interface{})
+		if err != nil || i < 0 || i > len(list) {
+			return nil, false
+		}
+		if i == len(list) {
+			list = append(list, nil)
+		}
+		var elem reflect.Type
+		if t != nil {
+			elem = t.Elem()
+		}
+		v, ok := setPath(list[i], elem, path[1:], value)
+		if ok {
+			list[i] = v
+		}
+		return list, ok
+	}
+
+	m, _ := tree.(map[string]interface{})
+	if list, ok := tree.([]interface{}); ok && t == tableType { // the legacy time table
+		m = map[string]interface{}{"entries": list}
+	}
+	if m == nil {
+		m = make(map[string]interface{})
+	}
+	var name string
+	var field reflect.Type
+	n := 0
+	switch {
+	case isStruct(t):
+		name, field, n = findField(t, path)
+	case t != nil && t.Kind() == reflect.Map:
+		field = t.Elem()
+		fallthrough
+	default: // the existing key or the rest of the path as a camel case key
+		for k := range m {
+			if words := strings.Split(upperSnake(k), "_"); len(words) > n && hasPrefix(path, words) {
+				name, n = k, len(words)
+			}
+		}
+		if n == 0 && t != nil && t.Kind() == reflect.Map {
+			name, n = path[0], 1
+		} else if n == 0 {
+			name, n = camelCase(path), len(path)
+		}
+	}
+	if n == 0 {
+		return nil, false
+	}
+	v, ok := setPath(m[name], field, path[n:], value)
+	if ok {
+		m[name] = v
+	}
+	return m, ok
+}
+
+// hasField report whether path is a field of t, e.g. ACCOUNTS 0 FILE
+func hasField(t reflect.Type, path []string) bool {
+	for len(path) != 0 {
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		switch {
+		case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+			if _, err := strconv.Atoi(path[0]); err != nil {
+				return false
+			}
+			t, path = t.Elem(), path[1:]
+		case t.Kind() == reflect.Map:
+			t, path = t.Elem(), path[1:]
+		case isStruct(t):
+			_, field, n := findField(t, path)
+			if n == 0 {
+				return false
+			}
+			t, path = field, path[n:]
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+var (
+	unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	tableType       = reflect.TypeOf(schedule.Table{})
+)
+
+// isStruct report whether t is a struct decoded by the fields, the struct
+// implementing json.Unmarshaler like schedule.Table is not
+func isStruct(t reflect.Type) bool {
+	return t != nil && t.Kind() == reflect.Struct && !reflect.PointerTo(t).Implements(unmarshalerType)
+}
+
+// findField return the json name and the type of the field of the struct
+// matching the longest prefix of path, and the number of the words matched
+func findField(t reflect.Type, path []string) (name string, field reflect.Type, n int) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := strings.Split(f.Tag.Get("json"), ",")[0]
+		if tag == "-" || !f.IsExported() {
+			continue
+		}
+		if f.Anonymous && tag == "" { // embedded struct, e.g. client.Account
+			if k, v, m := findField(f.Type, path); m > n {
+				name, field, n = k, v, m
+			}
+			continue
+		}
+		if tag == "" {
+			tag = f.Name
+		}
+		if words := strings.Split(upperSnake(tag), "_"); len(words) > n && hasPrefix(path, words) {
+			name, field, n = tag, f.Type, len(words)
+		}
+	}
+	return
+}
+
+// parseValue parse the value of the environment variable, the strings are
+// kept, the others are decoded as json and fall back to string, e.g. "30m"
+func parseValue(value string, t reflect.Type) interface{} {
+	if t != nil && t.Kind() == reflect.String {
+		return value
+	}
+	var v interface{}
+	dec := json.NewDecoder(strings.NewReader(value))
+	dec.UseNumber()
+	if dec.Decode(&v) != nil || dec.More() {
+		return value
+	}
+	return v
+}
+
+// upperSnake convert the camel case name to upper snake case,
+// e.g. timeTable -> TIME_TABLE, SMTP -> SMTP
+func upperSnake(s string) string {
+	r := []rune(s)
+	b := &strings.Builder{}
+	for i, c := range r {
+		if i > 0 && unicode.IsUpper(c) &&
+			(unicode.IsLower(r[i-1]) || i+1 < len(r) && unicode.IsLower(r[i+1]) && unicode.IsUpper(r[i-1])) {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToUpper(c))
+	}
+	return b.String()
+}
+
+// camelCase convert the upper snake case words to camel case, e.g. TIME ZONE -> timeZone
+func camelCase(words []string) string {
+	b := &strings.Builder{}
+	for i, w := range words {
+		w = strings.ToLower(w)
+		if i > 0 && w != "" {
+			w = strings.ToUpper(w[:1]) + w[1:]
+		}
+		b.WriteString(w)
+	}
+	return b.String()
+}
+
+func hasPrefix(path, words []string) bool {
+	if len(words) > len(path) {
+		return false
+	}
+	for i, w := range words {
+		if path[i] != w {
+			return false
+		}
+	}
+	return true
+}
+
+func isSlice(v interface{}) bool {
+	_, ok := v.([]interface{})
+	return ok
+}
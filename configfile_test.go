@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestApplyEnv(t *testing.T) {
+	secret := filepath.Join(t.TempDir(), "smtp")
+	if err := os.WriteFile(secret, []byte("smtp-secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		tree    string
+		env     []string
+		want    string
+		unknown []string
+	}{
+		{
+			name: "nested struct",
+			tree: `{}`,
+			env:  []string{"REPORT_STAT_SERVER_LISTEN=:8080", "REPORT_STAT_SCHEDULE_MAX_ATTEMPTS=5", "REPORT_STAT_SERVER_GRACE=30s"},
+			want: `{"schedule":{"maxAttempts":5},"server":{"grace":"30s","listen":":8080"}}`,
+		},
+		{
+			name: "existing value",
+			tree: `{"server":{"listen":":80","token":"t"}}`,
+			env:  []string{"REPORT_STAT_SERVER_LISTEN=:8080"},
+			want: `{"server":{"listen":":8080","token":"t"}}`,
+		},
+		{
+			name: "embedded account",
+			tree: `{"accounts":[{"username":"u","password":"old"}]}`,
+			env:  []string{"REPORT_STAT_ACCOUNTS_0_PASSWORD=new", "REPORT_STAT_ACCOUNTS_0_KEY=2018"},
+			want: `{"accounts":[{"key":"2018","password":"new","username":"u"}]}`,
+		},
+		{
+			name: "append to slice",
+			tree: `{"accounts":[{"username":"u"}]}`,
+			env:  []string{"REPORT_STAT_ACCOUNTS_1_USERNAME=v", "REPORT_STAT_SCHEDULE_HOLIDAY_TAGS_0=假期"},
+			want: `{"accounts":[{"username":"u"},{"username":"v"}],"schedule":{"holidayTags":["假期"]}}`,
+		},
+		{
+			name:    "index out of range",
+			tree:    `{"accounts":[{"username":"u"}]}`,
+			env:     []string{"REPORT_STAT_ACCOUNTS_2_USERNAME=v", "REPORT_STAT_ACCOUNTS_X_USERNAME=v"},
+			want:    `{"accounts":[{"username":"u"}]}`,
+			unknown: []string{"REPORT_STAT_ACCOUNTS_2_USERNAME", "REPORT_STAT_ACCOUNTS_X_USERNAME"},
+		},
+		{
+			name: "map",
+			tree: `{"accounts":[{"contacts":{"a1":[{"email":"a@x"}]},"notifiers":[{"type":"webhook"}]}]}`,
+			env:  []string{"REPORT_STAT_ACCOUNTS_0_CONTACTS_A1_0_EMAIL=b@x", "REPORT_STAT_ACCOUNTS_0_NOTIFIERS_0_HEADERS_TOKEN=t"},
+			want: `{"accounts":[{"contacts":{"a1":[{"email":"b@x"}]},"notifiers":[{"headers":{"TOKEN":"t"},"type":"webhook"}]}]}`,
+		},
+		{
+			name: "file fields",
+			tree: `{"accounts":[{}],"email":{"SMTP":{"host":"h"}}}`,
+			env: []string{
+				"REPORT_STAT_ACCOUNTS_0_PASSWORD_FILE=/run/secrets/pw", // a field of the account
+				"REPORT_STAT_ACCOUNTS_0_FILE=form.json",                // a field of the embedded account
+				"REPORT_STAT_EMAIL_SMTP_PASSWORD_FILE=" + secret,       // read from the file
+			},
+			want: `{"accounts":[{"file":"form.json","passwordFile":"/run/secrets/pw"}],"email":{"SMTP":{"host":"h","password":"smtp-secret"}}}`,
+		},
+		{
+			name: "legacy time table",
+			tree: `{"timeTable":[{"hour":15,"minute":0}]}`,
+			env:  []string{"REPORT_STAT_TIME_TABLE_TIME_ZONE=Asia/Shanghai"},
+			want: `{"timeTable":{"entries":[{"hour":15,"minute":0}],"timeZone":"Asia/Shanghai"}}`,
+		},
+		{
+			name:    "unknown",
+			tree:    `{}`,
+			env:     []string{"REPORT_STAT_SEVER_LISTEN=:8080", "REPORT_STAT_CONFIG=config.yaml", "REPORT_STAT_TOKEN=t", "HOME=/root"},
+			want:    `{}`,
+			unknown: []string{"REPORT_STAT_SEVER_LISTEN"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var tree interface{}
+			dec := json.NewDecoder(strings.NewReader(tt.tree))
+			dec.UseNumber()
+			if err := dec.Decode(&tree); err != nil {
+				t.Fatal(err)
+			}
+			tree, unknown, err := applyEnv(tree, reflect.TypeOf(&configFile{}), tt.env)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := json.Marshal(tree)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("applyEnv = %s, want %s", data, tt.want)
+			}
+			if !reflect.DeepEqual(unknown, tt.unknown) {
+				t.Errorf("unknown = %q, want %q", unknown, tt.unknown)
+			}
+		})
+	}
+}
+
+func TestUpperSnake(t *testing.T) {
+	for s, want := range map[string]string{
+		"listen":       "LISTEN",
+		"timeTable":    "TIME_TABLE",
+		"maxAttempts":  "MAX_ATTEMPTS",
+		"SMTP":         "SMTP",
+		"TLS":          "TLS",
+		"passwordFile": "PASSWORD_FILE",
+		"getHTTPPage":  "GET_HTTP_PAGE",
+	} {
+		if got := upperSnake(s); got != want {
+			t.Errorf("upperSnake(%q) = %q, want %q", s, got, want)
+		}
+	}
+	if got := camelCase([]string{"TIME", "ZONE"}); got != "timeZone" {
+		t.Errorf("camelCase = %q, want timeZone", got)
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	client "report-stat/httpclient"
+	"report-stat/secret"
+
+	"golang.org/x/term"
+)
+
+// keyPath the key file to decrypt the passwords
+var keyPath = "config/secret.key"
+
+// password return the plain password of the account, the password is read from
+// PasswordFile if set and decrypted with the key file if encrypted.
+// The password is added to the log redactor.
+func (account *accountConfig) password() (string, error) {
+	p := account.Password
+	if account.PasswordFile != "" {
+		data, err := secret.ReadFile(account.PasswordFile)
+		if err != nil {
+			return "", err
+		}
+		p = strings.TrimRight(string(data), "\r\n")
+	}
+	if secret.Encrypted(p) {
+		key, err := secret.LoadKey(keyPath, false)
+		if err != nil {
+			return "", err
+		}
+		if p, err = secret.Decrypt(key, p); err != nil {
+			return "", err
+		}
+	}
+	logRedactor.add(p)
+	return p, nil
+}
+
+// plain return a copy of the account with the plain password to login,
+// the password is decrypted only in memory
+func (account *accountConfig) plain() (*client.Account, error) {
+	p, err := account.password()
+	if err != nil {
+		return nil, fmt.Errorf("account %s: password: %w", account.Name, err)
+	}
+	a := account.Account
+	a.Password = p
+	return &a, nil
+}
+
+// redactor replace the secrets in the log with "******"
+type redactor struct {
+	w io.Writer
+
+	mu       sync.RWMutex
+	secrets  map[string]bool
+	replacer *strings.Replacer
+}
+
+// logRedactor the output of the logger
+var logRedactor = &redactor{w: os.Stderr}
+
+// add add the secret and its url encoded form
+func (r *redactor) add(s string) {
+	if s == "" {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.secrets[s] {
+		return
+	}
+	if r.secrets == nil {
+		r.secrets = make(map[string]bool)
+	}
+	r.secrets[s] = true
+	r.secrets[url.QueryEscape(s)] = true
+	if q := strconv.Quote(s); q[1:len(q)-1] != s { // the escaped form in the logs
+		r.secrets[q[1:len(q)-1]] = true
+	}
+	var oldnew []string
+	for k := range r.secrets {
+		oldnew = append(oldnew, k, "******")
+	}
+	r.replacer = strings.NewReplacer(oldnew...)
+}
+
+// redact return s with the secrets replaced
+func (r *redactor) redact(s string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.replacer == nil {
+		return s
+	}
+	return r.replacer.Replace(s)
+}
+
+// Write implement io.Writer
+func (r *redactor) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(r.w, r.redact(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// printf print the formatted message to stdout with the secrets redacted,
+// the output of the commands goes through it like the logs
+func printf(format string, a ...interface{}) {
+	fmt.Print(logRedactor.redact(fmt.Sprintf(format, a...)))
+}
+
+// encryptPasswordCommand encrypt the password read from the terminal or stdin
+// with the key file, the key file is created if it does not exist
+func encryptPasswordCommand(flagSet *flag.FlagSet, args []string) int {
+	out := flagSet.String("out", "", "write the encrypted password to the credentials `file` with mode 0600 instead of stdout")
+	flagSet.Parse(args)
+
+	key, err := secret.LoadKey(keyPath, true)
+	if err != nil {
+		logger.Error(err.Error())
+		return exitError
+	}
+	var p string
+	if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) {
+		fmt.Fprint(os.Stderr, "Password: ")
+		data, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			logger.Error(err.Error())
+			return exitError
+		}
+		p = string(data)
+	} else {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && err != io.EOF {
+			logger.Error(err.Error())
+			return exitError
+		}
+		p = strings.TrimRight(line, "\r\n")
+	}
+	if p == "" {
+		logger.Error("encrypt-password: empty password")
+		return exitUsage
+	}
+	text, err := secret.Encrypt(key, p)
+	if err != nil {
+		logger.Error(err.Error())
+		return exitError
+	}
+	if *out == "" {
+		fmt.Println(text)
+		return exitOK
+	}
+	f, err := os.OpenFile(*out, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err == nil {
+		if err = f.Chmod(0600); err == nil { // the existing file may be accessible by others
+			_, err = f.WriteString(text + "\n")
+		}
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		logger.Error(err.Error())
+		return exitError
+	}
+	return exitOK
+}
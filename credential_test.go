@@ -0,0 +1,38 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRedactor(t *testing.T) {
+	b := &strings.Builder{}
+	r := &redactor{w: b}
+	if got := r.redact("no secret"); got != "no secret" {
+		t.Errorf("redact without secrets = %q", got)
+	}
+	r.add("")
+	r.add(`p&ss"word`)
+	r.add(`p&ss"word`) // added twice
+	r.add("smtp")
+	tests := []struct {
+		in, want string
+	}{
+		{`login p&ss"word failed`, "login ****** failed"},
+		{"password=p%26ss%22word", "password=******"},                    // url encoded
+		{`error="bad p&ss\"word"`, `error="bad ******"`},                 // quoted by the logger
+		{"smtp: auth failed for smtp", "******: auth failed for ******"}, // every occurrence
+		{"nothing", "nothing"},
+	}
+	for _, test := range tests {
+		if got := r.redact(test.in); got != test.want {
+			t.Errorf("redact(%q) = %q, want %q", test.in, got, test.want)
+		}
+	}
+	if _, err := r.Write([]byte("user smtp\n")); err != nil {
+		t.Fatal(err)
+	}
+	if got := b.String(); got != "user ******\n" {
+		t.Errorf("Write = %q", got)
+	}
+}
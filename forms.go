@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	client "report-stat/httpclient"
+)
+
+// formsCommand print the students who have not reported, return the exit code
+func formsCommand(flagSet *flag.FlagSet, args []string) int {
+	name := flagSet.String("name", "", "only print the account with the `name`")
+	date := flagSet.String("date", "", "set the `date` of the form, format: 2006-01-02 (default: today)")
+	jsonOut := flagSet.Bool("json", false, "output in json format")
+	flagSet.Parse(args)
+
+	if *date == "" {
+		*date = time.Now().In(timeZone).Format("2006-01-02")
+	} else if _, err := time.Parse("2006-01-02", *date); err != nil {
+		logger.Error(err.Error())
+		return exitUsage
+	}
+	accounts, err := loadAccounts(accountPath)
+	if err == nil {
+		accounts, err = accounts.filter(*name)
+	}
+	if err != nil {
+		logger.Error(err.Error())
+		return exitError
+	}
+
+	ctx, cc := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cc()
+	type output struct {
+		Name string `json:"name"`
+		*client.Form
+	}
+	var forms []output
+	for _, account := range accounts {
+		plain, err := account.plain()
+		if err != nil {
+			logger.Error(err.Error())
+			return exitError
+		}
+		c, cancel := context.WithTimeout(ctx, 50*time.Second)
+		form, err := client.GetForm(c, plain, *date)
+		cancel()
+		if err != nil {
+			logger.Error(err.Error(), "account", account.Name)
+			return exitUpstream
+		}
+		forms = append(forms, output{Name: account.Name, Form: form})
+	}
+
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "\t")
+		enc.SetEscapeHTML(false)
+		if err = enc.Encode(forms); err != nil {
+			logger.Error(err.Error())
+			return exitError
+		}
+		return exitOK
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, form := range forms {
+		fmt.Fprintf(w, "[%s] %s 共 %d 人未填报\n", form.Name, form.Date, len(form.Students))
+		for _, v := range form.Students {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", v.ID, v.Name, v.Class)
+		}
+	}
+	if err = w.Flush(); err != nil {
+		logger.Error(err.Error())
+		return exitError
+	}
+	return exitOK
+}
+
+// renderCommand render the images from the saved data.json, return the exit code
+func renderCommand(flagSet *flag.FlagSet, args []string) int {
+	name := flagSet.String("name", "", "only render the account with the `name`")
+	data := flagSet.String("data", "", "set the data `file` (default: the file of the account)")
+	out := flagSet.String("out", "", "set the output `directory` (default: the out of the account)")
+	flagSet.Parse(args)
+
+	accounts, err := loadAccounts(accountPath)
+	if err == nil {
+		accounts, err = accounts.filter(*name)
+	}
+	if err != nil {
+		logger.Error(err.Error())
+		return exitError
+	}
+	for _, account := range accounts {
+		a := account.Account
+		if *data != "" {
+			a.File = *data
+		}
+		if *out != "" {
+			a.Out = *out
+		}
+		if a.File == "" || a.Out == "" {
+			logger.Error("The data file or the output directory is not set", "account", account.Name)
+			return exitUsage
+		}
+		b, err := os.ReadFile(a.File)
+		if err != nil {
+			logger.Error(err.Error())
+			return exitError
+		}
+		res, err := client.Render(context.Background(), &a, b)
+		if err != nil {
+			logger.Error(err.Error(), "account", account.Name)
+			return exitError
+		}
+		fmt.Printf("%s: %d images rendered to %s, %d remaining\n", account.Name, len(res.Images), a.Out, res.Total)
+	}
+	return exitOK
+}
@@ -9,6 +9,13 @@ require (
 
 require golang.org/x/image v0.0.0-20220321031419-a8550c1d254a
 
-require github.com/kolesa-team/go-webp v1.0.1
+require (
+	github.com/kolesa-team/go-webp v1.0.1
+	golang.org/x/term v0.5.0
+	gopkg.in/yaml.v3 v3.0.1
+)
 
-require golang.org/x/text v0.3.6 // indirect
+require (
+	golang.org/x/sys v0.5.0 // indirect
+	golang.org/x/text v0.3.6 // indirect
+)
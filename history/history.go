@@ -0,0 +1,206 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	client "report-stat/httpclient"
+)
+
+const (
+	dateLayout = "2006-01-02"
+	fileExt    = ".jsonl"
+)
+
+// ErrInvalidDate date is not in format 2006-01-02
+var ErrInvalidDate = errors.New("history: invalid date")
+
+// Snapshot students who had not reported at a time slot
+type Snapshot struct {
+	Date     string           `json:"date"` // date of the form, format: 2006-01-02
+	Slot     string           `json:"slot"` // time slot, format: 15:04
+	Wid      string           `json:"wid"`
+	Key      string           `json:"key"`
+	Time     int64            `json:"time"` // fetch time, unix timestamp
+	Students []client.Student `json:"students"`
+}
+
+// Query filter of the snapshots, empty field matches all
+type Query struct {
+	From  string // first date, format: 2006-01-02
+	To    string // last date, format: 2006-01-02
+	Slot  string
+	Wid   string
+	Key   string
+	Class string // when set, only the students of the class are kept
+}
+
+// Store append-only snapshot store, the snapshots of a day are
+// stored in one file as JSON lines
+type Store struct {
+	dir       string
+	retention int // days to keep, 0 means forever
+
+	mu sync.Mutex
+}
+
+// Open open the store in dir, the snapshots older than retention days
+// are removed when a new day begins
+func Open(dir string, retention int) (*Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &Store{dir: dir, retention: retention}, nil
+}
+
+// Append append the snapshot to the store
+func (s *Store) Append(snap *Snapshot) error {
+	if _, err := time.Parse(dateLayout, snap.Date); err != nil {
+		return ErrInvalidDate
+	}
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	name := filepath.Join(s.dir, snap.Date+fileExt)
+	_, err = os.Stat(name)
+	newDay := os.IsNotExist(err)
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	if newDay {
+		err = s.prune(snap.Date)
+	}
+	return err
+}
+
+// Query return the snapshots matching q in chronological order
+func (s *Store) Query(q Query) ([]*Snapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dates, err := s.dates()
+	if err != nil {
+		return nil, err
+	}
+	var res []*Snapshot
+	for _, date := range dates {
+		if (q.From != "" && date < q.From) || (q.To != "" && date > q.To) {
+			continue
+		}
+		if res, err = s.load(res, date, &q); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// Prune remove the snapshots older than the retention days before today
+func (s *Store) Prune(today string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.prune(today)
+}
+
+func (s *Store) prune(today string) error {
+	if s.retention <= 0 {
+		return nil
+	}
+	t, err := time.Parse(dateLayout, today)
+	if err != nil {
+		return ErrInvalidDate
+	}
+	expire := t.AddDate(0, 0, -s.retention).Format(dateLayout)
+	dates, err := s.dates()
+	if err != nil {
+		return err
+	}
+	for _, date := range dates {
+		if date >= expire {
+			break
+		}
+		if err = os.Remove(filepath.Join(s.dir, date+fileExt)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dates return the dates stored, sorted
+func (s *Store) dates() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, fileExt) {
+			continue
+		}
+		date := strings.TrimSuffix(name, fileExt)
+		if _, err := time.Parse(dateLayout, date); err == nil {
+			res = append(res, date)
+		}
+	}
+	return res, nil // os.ReadDir returns entries sorted by filename
+}
+
+// load append the matched snapshots of the date to res.
+// The lines that cannot be decoded (e.g. partially written) are skipped.
+func (s *Store) load(res []*Snapshot, date string, q *Query) ([]*Snapshot, error) {
+	f, err := os.Open(filepath.Join(s.dir, date+fileExt))
+	if err != nil {
+		return res, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 16<<20)
+	for scanner.Scan() {
+		snap := &Snapshot{}
+		if json.Unmarshal(scanner.Bytes(), snap) != nil {
+			continue
+		}
+		if (q.Slot != "" && snap.Slot != q.Slot) ||
+			(q.Wid != "" && snap.Wid != q.Wid) ||
+			(q.Key != "" && snap.Key != q.Key) {
+			continue
+		}
+		if q.Class != "" {
+			snap.Students = snap.Class(q.Class)
+		}
+		res = append(res, snap)
+	}
+	return res, scanner.Err()
+}
+
+// Class return the students of the class, "全部" means all the classes
+func (snap *Snapshot) Class(name string) []client.Student {
+	if name == "全部" {
+		return snap.Students
+	}
+	res := make([]client.Student, 0)
+	for _, student := range snap.Students {
+		if student.Class == name {
+			res = append(res, student)
+		}
+	}
+	return res
+}
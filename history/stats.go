@@ -0,0 +1,103 @@
+package history
+
+import (
+	"fmt"
+	"sort"
+
+	client "report-stat/httpclient"
+)
+
+// StudentStat statistics of a student in a window
+type StudentStat struct {
+	client.Student
+	DaysMissed int    `json:"daysMissed"` // days still missing at the last check of the day
+	Streak     int    `json:"streak"`     // current consecutive missed days
+	ReportTime string `json:"reportTime"` // time of day the student usually reports, format: 15:04, empty if unknown
+}
+
+// Stats compute the statistics of each student appeared in the snapshots.
+// The snapshots must be in chronological order, e.g. the result of Store.Query.
+//
+// A day is missed if the student is still in the last snapshot of the day.
+// The report time of a day is the first slot the student is no longer in.
+// The date open, e.g. today before its last slot, is not counted as missed,
+// only the report times of it are used.
+func Stats(snaps []*Snapshot, open string) []StudentStat {
+	type record struct {
+		stat  StudentStat
+		times []int // report time of each day, minutes of the day
+	}
+	records := make(map[string]*record)
+	for i := 0; i < len(snaps); {
+		// snapshots of the same date
+		j := i + 1
+		for j < len(snaps) && snaps[j].Date == snaps[i].Date {
+			j++
+		}
+		seen := make(map[string]bool) // value: in the previous snapshot
+		for _, snap := range snaps[i:j] {
+			in := make(map[string]bool, len(snap.Students))
+			for _, student := range snap.Students {
+				in[student.ID] = true
+				if _, ok := records[student.ID]; !ok {
+					records[student.ID] = &record{stat: StudentStat{Student: student}}
+				}
+				seen[student.ID] = true
+			}
+			minute, ok := parseSlot(snap.Slot)
+			for id, prev := range seen {
+				if prev && !in[id] && ok {
+					records[id].times = append(records[id].times, minute)
+				}
+				seen[id] = in[id]
+			}
+		}
+		last := snaps[j-1]
+		if last.Date == open {
+			i = j
+			continue
+		}
+		lastIn := make(map[string]bool, len(last.Students))
+		for _, student := range last.Students {
+			lastIn[student.ID] = true
+		}
+		for id, r := range records {
+			if lastIn[id] {
+				r.stat.DaysMissed++
+				r.stat.Streak++
+			} else {
+				r.stat.Streak = 0
+			}
+		}
+		i = j
+	}
+
+	res := make([]StudentStat, 0, len(records))
+	for _, r := range records {
+		if len(r.times) != 0 {
+			sort.Ints(r.times)
+			m := r.times[len(r.times)/2] // median
+			r.stat.ReportTime = fmt.Sprintf("%02d:%02d", m/60, m%60)
+		}
+		res = append(res, r.stat)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].DaysMissed != res[j].DaysMissed {
+			return res[i].DaysMissed > res[j].DaysMissed
+		}
+		if res[i].Streak != res[j].Streak {
+			return res[i].Streak > res[j].Streak
+		}
+		return res[i].ID < res[j].ID
+	})
+	return res
+}
+
+// parseSlot return the minutes of the day
+func parseSlot(slot string) (int, bool) {
+	var hour, minute int
+	if _, err := fmt.Sscanf(slot, "%d:%d", &hour, &minute); err != nil {
+		return 0, false
+	}
+	return hour*60 + minute, true
+}
@@ -0,0 +1,100 @@
+package history
+
+import (
+	"reflect"
+	"testing"
+
+	client "report-stat/httpclient"
+)
+
+// snapshot return the snapshot of the students with the ids
+func snapshot(date, slot string, ids ...string) *Snapshot {
+	snap := &Snapshot{Date: date, Slot: slot, Students: []client.Student{}}
+	for _, id := range ids {
+		snap.Students = append(snap.Students, client.Student{ID: id, Name: "s" + id, Class: "A"})
+	}
+	return snap
+}
+
+func TestStats(t *testing.T) {
+	tests := []struct {
+		name  string
+		snaps []*Snapshot
+		open  string
+		want  []StudentStat
+	}{
+		{
+			name: "missed and report times",
+			snaps: []*Snapshot{
+				snapshot("2024-05-01", "08:00", "1", "2", "3"),
+				snapshot("2024-05-01", "12:00", "1", "2"),
+				snapshot("2024-05-01", "20:00", "1"),
+				snapshot("2024-05-02", "08:00", "1", "2"),
+				snapshot("2024-05-02", "12:00", "1"),
+				snapshot("2024-05-02", "20:00"),
+				snapshot("2024-05-03", "08:00", "1", "2", "3"),
+				snapshot("2024-05-03", "09:00", "1", "2"),
+				snapshot("2024-05-03", "20:00", "1"),
+				snapshot("2024-05-04", "08:00", "1", "2"), // open, not missed
+				snapshot("2024-05-04", "10:00", "1"),
+			},
+			open: "2024-05-04",
+			want: []StudentStat{
+				{Student: client.Student{ID: "1", Name: "s1", Class: "A"}, DaysMissed: 2, Streak: 1, ReportTime: "20:00"},
+				// 10:00, 12:00, 20:00, 20:00, the upper median
+				{Student: client.Student{ID: "2", Name: "s2", Class: "A"}, ReportTime: "20:00"},
+				// 09:00, 12:00
+				{Student: client.Student{ID: "3", Name: "s3", Class: "A"}, ReportTime: "12:00"},
+			},
+		},
+		{
+			name: "streak",
+			snaps: []*Snapshot{
+				snapshot("2024-05-01", "20:00", "1", "2"),
+				snapshot("2024-05-02", "20:00", "1", "2"),
+				snapshot("2024-05-03", "15:00", "1", "2"),
+				snapshot("2024-05-03", "20:00", "1"),
+				snapshot("2024-05-04", "20:00", "1"),
+			},
+			want: []StudentStat{
+				{Student: client.Student{ID: "1", Name: "s1", Class: "A"}, DaysMissed: 4, Streak: 4},
+				{Student: client.Student{ID: "2", Name: "s2", Class: "A"}, DaysMissed: 2, Streak: 0, ReportTime: "20:00"},
+			},
+		},
+		{
+			name: "median of odd days",
+			snaps: []*Snapshot{
+				snapshot("2024-05-01", "08:00", "1"),
+				snapshot("2024-05-01", "22:00"),
+				snapshot("2024-05-02", "08:00", "1"),
+				snapshot("2024-05-02", "09:30"),
+				snapshot("2024-05-03", "08:00", "1"),
+				snapshot("2024-05-03", "12:15"),
+			},
+			want: []StudentStat{
+				{Student: client.Student{ID: "1", Name: "s1", Class: "A"}, ReportTime: "12:15"},
+			},
+		},
+		{
+			name: "invalid slot",
+			snaps: []*Snapshot{
+				snapshot("2024-05-01", "08:00", "1"),
+				snapshot("2024-05-01", "noon"),
+			},
+			want: []StudentStat{
+				{Student: client.Student{ID: "1", Name: "s1", Class: "A"}},
+			},
+		},
+		{
+			name: "empty",
+			want: []StudentStat{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := Stats(test.snaps, test.open); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Stats =\n%+v\nwant\n%+v", got, test.want)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package httpclient
 import (
 	"context"
 	"crypto/tls"
+	"encoding/json"
 	"net/http"
 	"net/url"
 	"sort"
@@ -23,7 +24,64 @@ func LoginConfirm(ctx context.Context, account *Account, timeout time.Duration)
 }
 
 // GetFormData get form data
-func GetFormData(ctx context.Context, account *Account) (empty bool, err error) {
+func GetFormData(ctx context.Context, account *Account) (res *Result, err error) {
+	now := time.Now()
+	date := now.In(timeZone).Format("2006-01-02")
+	result, err := getDetail(ctx, account, date)
+	if err != nil {
+		return
+	}
+	if res, err = newResult(result, date, now); err != nil {
+		return
+	}
+	if account.File != "" {
+		if err = WriteFile(account.File, res.Data); err != nil {
+			return
+		}
+	}
+	err = generateImage(ctx, result, account, res)
+	return
+}
+
+// GetForm get the students who have not reported on the date without
+// rendering the images, date format: 2006-01-02
+func GetForm(ctx context.Context, account *Account, date string) (*Form, error) {
+	result, err := getDetail(ctx, account, date)
+	if err != nil {
+		return nil, err
+	}
+	return &Form{
+		Date:     date,
+		Classes:  result.classNames(),
+		Students: result.students(),
+	}, nil
+}
+
+// Render render the images from data, the content of data.json written by
+// GetFormData, the images are written to account.Out if it is not empty
+func Render(ctx context.Context, account *Account, data []byte) (res *Result, err error) {
+	var d struct {
+		FormData     [][3]string `json:"formData"` // id, name, class
+		LastModified int64       `json:"lastModified"`
+	}
+	if err = json.Unmarshal(data, &d); err != nil {
+		return
+	}
+	result := make(detailArray, len(d.FormData))
+	for i, v := range d.FormData {
+		result[i][3], result[i][4], result[i][7] = v[0], v[1], v[2]
+	}
+	sort.Sort(result)
+	now := time.Unix(d.LastModified, 0)
+	if res, err = newResult(result, now.In(timeZone).Format("2006-01-02"), now); err != nil {
+		return
+	}
+	err = generateImage(ctx, result, account, res)
+	return
+}
+
+// getDetail login and get the form detail of the date, the result is sorted
+func getDetail(ctx context.Context, account *Account, date string) (result detailArray, err error) {
 	defer func() {
 		err = parseURLError(err)
 	}()
@@ -40,22 +98,27 @@ func GetFormData(ctx context.Context, account *Account) (empty bool, err error)
 		return
 	}
 
-	var result detailArray
-	result, err = c.getFormDetail(account.Wid, account.Key) // 获取打卡列表信息
+	result, err = c.getFormDetail(date, account.Wid, account.Key) // 获取打卡列表信息
 	if err != nil {
 		return
 	}
 	sort.Sort(result) // sort result
-	err = storeJson(dumps{
+	return
+}
+
+// newResult create the result without images from the sorted detail
+func newResult(result detailArray, date string, now time.Time) (res *Result, err error) {
+	res = &Result{
+		Date:         date,
+		Students:     result.students(),
+		Total:        len(result),
+		LastModified: now,
+	}
+	res.Data, err = marshalJson(dumps{
 		FormData:     result,
 		ClassName:    result.classNames(),
-		LastModified: time.Now().Unix(),
-	}, account.File)
-	if err != nil {
-		return
-	}
-	err = generateImage(ctx, result, account, time.Now().Unix())
-	empty = len(result) == 0
+		LastModified: now.Unix(),
+	})
 	return
 }
 
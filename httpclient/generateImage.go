@@ -1,7 +1,7 @@
 package httpclient
 
 import (
-	"bufio"
+	"bytes"
 	"context"
 	"image"
 	"image/color"
@@ -10,6 +10,8 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"sync"
+	"time"
 
 	"github.com/kolesa-team/go-webp/encoder"
 	"github.com/kolesa-team/go-webp/webp"
@@ -19,42 +21,64 @@ import (
 	"golang.org/x/image/math/fixed"
 )
 
-var fonts *sfnt.Font
+// FontFile the font file to render the images, default: font.otf or font.ttf in the working directory
+var FontFile string
+
+var (
+	fonts    *sfnt.Font
+	fontErr  error
+	fontOnce sync.Once
+)
 
 type status struct {
 	LastModified int64          `json:"lastModified"`
 	Remains      map[string]int `json:"remains"`
 }
 
-func init() {
-	data, err := os.ReadFile("font.otf")
-	if err != nil {
-		data, err = os.ReadFile("font.ttf")
-		if err != nil {
-			panic(err)
+// LoadFont load FontFile once, it is called before generating the images
+func LoadFont() error {
+	fontOnce.Do(func() {
+		var data []byte
+		if FontFile != "" {
+			data, fontErr = os.ReadFile(FontFile)
+		} else if data, fontErr = os.ReadFile("font.otf"); fontErr != nil {
+			data, fontErr = os.ReadFile("font.ttf")
 		}
-	}
-	fonts, err = opentype.Parse(data)
-	if err != nil {
-		panic(err)
-	}
+		if fontErr == nil {
+			fonts, fontErr = opentype.Parse(data)
+		}
+	})
+	return fontErr
 }
 
 // generateImage generate image from detail array
 //
 // Note: detail must be sorted
-func generateImage(ctx context.Context, detail detailArray, account *Account, lastModified int64) (err error) {
+func generateImage(ctx context.Context, detail detailArray, account *Account, res *Result) (err error) {
+	if err = LoadFont(); err != nil {
+		return
+	}
+	start := time.Now()
+	defer func() {
+		if err == nil {
+			renderDuration.Observe(time.Since(start).Seconds())
+		}
+	}()
 	if !sort.StringsAreSorted(account.Class) {
 		sort.Strings(account.Class)
 	}
-	if err = os.MkdirAll(account.Out, 0755); err != nil {
-		return
+	if account.Out != "" {
+		if err = os.MkdirAll(account.Out, 0755); err != nil {
+			return
+		}
 	}
 	end := 0
 	stat := status{
-		LastModified: lastModified,
+		LastModified: res.LastModified.Unix(),
 		Remains:      make(map[string]int, len(account.Class)),
 	}
+	res.Images = make(map[string][]byte, len(account.Class))
+	res.pictures = make(map[string]*image.RGBA, len(account.Class))
 	for _, classname := range account.Class {
 		select {
 		case <-ctx.Done():
@@ -74,14 +98,31 @@ func generateImage(ctx context.Context, detail detailArray, account *Account, la
 			}
 		}
 		stat.Remains[classname] = len(data)
-		if err = toPic(data, filepath.Join(account.Out, classname+".webp"), classname == "全部"); err != nil {
+		img := drawTable(data, classname == "全部")
+		res.pictures[classname] = img
+		var pic []byte
+		if pic, err = encodeWebp(img); err != nil {
 			return
 		}
+		res.Images[classname] = pic
+		if account.Out != "" {
+			if err = WriteFile(filepath.Join(account.Out, classname+".webp"), pic); err != nil {
+				return
+			}
+		}
+	}
+	res.Remains = stat.Remains
+	if res.Status, err = marshalJson(stat); err != nil {
+		return
 	}
-	return storeJson(stat, filepath.Join(account.Out, "status.json"))
+	if account.Out != "" {
+		err = WriteFile(filepath.Join(account.Out, "status.json"), res.Status)
+	}
+	return
 }
 
-func toPic(detail detailArray, filename string, showClass bool) error {
+// drawTable draw the id, name and class(if showClass) of the students as a table
+func drawTable(detail detailArray, showClass bool) *image.RGBA {
 	// Initialize the context.
 	fg, bg := image.Black, image.White
 	ruler := color.RGBA{204, 204, 204, 0xff}
@@ -149,23 +190,20 @@ func toPic(detail detailArray, filename string, showClass bool) error {
 			print(width3, y, detail[i].class())
 		}
 	}
-	// Save that RGBA image to disk.
+	return rgba
+}
+
+// encodeWebp encode the image to webp
+func encodeWebp(img image.Image) ([]byte, error) {
 	op, err := encoder.NewLossyEncoderOptions(encoder.PresetDefault, 85)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	var outFile *os.File
-	outFile, err = os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
-	if err != nil {
-		return err
-	}
-	defer outFile.Close()
-	b := bufio.NewWriter(outFile)
-	err = webp.Encode(b, rgba, op)
-	if err != nil {
-		return err
+	b := &bytes.Buffer{}
+	if err = webp.Encode(b, img, op); err != nil {
+		return nil, err
 	}
-	return b.Flush()
+	return b.Bytes(), nil
 }
 
 func drawLine(rgba *image.RGBA, x1, y1, x2, y2 int, color color.RGBA) {
@@ -5,6 +5,9 @@ import (
 	"context"
 	"encoding/xml"
 	"errors"
+	"fmt"
+	"html"
+	"io"
 	"net/http"
 	"net/url"
 	"reflect"
@@ -14,8 +17,36 @@ import (
 	"github.com/google/go-querystring/query"
 )
 
-// ErrCouldNotLogin login failed
-var ErrCouldNotLogin = errors.New("could not login")
+var (
+	// ErrCouldNotLogin login failed, the errors of the reasons below wrap it
+	ErrCouldNotLogin = errors.New("could not login")
+	// ErrWrongPassword the username or the password is wrong
+	ErrWrongPassword = fmt.Errorf("%w: wrong username or password", ErrCouldNotLogin)
+	// ErrAccountLocked the account is locked, e.g. after too many failed logins
+	ErrAccountLocked = fmt.Errorf("%w: account locked", ErrCouldNotLogin)
+	// ErrCaptchaRequired the captcha is required, e.g. after several failed logins
+	ErrCaptchaRequired = fmt.Errorf("%w: captcha required", ErrCouldNotLogin)
+	// ErrPasswordExpired the password is expired and must be changed
+	ErrPasswordExpired = fmt.Errorf("%w: password expired", ErrCouldNotLogin)
+	// ErrLoginPageChanged the login form can not be parsed
+	ErrLoginPageChanged = fmt.Errorf("%w: unexpected login page layout", ErrCouldNotLogin)
+	// ErrNoLoginMessage the login is rejected without an error message, e.g. a
+	// maintenance page or the challenge of the firewall, it may succeed on retry
+	ErrNoLoginMessage = fmt.Errorf("%w: no error message", ErrCouldNotLogin)
+)
+
+// loginMessages the keywords of the messages on the login page and the errors,
+// checked in order. The keywords are specific, e.g. "密码错误，再输错 3 次账号将被
+// 锁定" is ErrWrongPassword, while "密码错误次数过多，账号已被锁定" is ErrAccountLocked.
+var loginMessages = []struct {
+	keywords []string
+	err      error
+}{
+	{[]string{"已被锁定", "已锁定", "已经被锁定", "已被冻结", "已冻结", "account is locked", "account has been locked"}, ErrAccountLocked},
+	{[]string{"密码已过期", "密码已经过期", "密码过期", "password has expired", "password expired"}, ErrPasswordExpired},
+	{[]string{"验证码", "captcha"}, ErrCaptchaRequired},
+	{[]string{"密码有误", "密码错误", "用户名或密码", "用户名或者密码", "username or password", "invalid credentials", "incorrect password", "wrong password"}, ErrWrongPassword},
+}
 
 type loginForm struct {
 	Username   string `url:"username"`
@@ -31,6 +62,14 @@ type loginForm struct {
 // login 登录系统
 func (c *punchClient) login(account *Account) (err error) {
 	const loginURL = "https://authserver.hhu.edu.cn/authserver/login"
+	start := time.Now()
+	loginAttempts.Inc()
+	defer func() {
+		loginDuration.Observe(time.Since(start).Seconds())
+		if err != nil {
+			loginFailures.Inc(loginReason(err))
+		}
+	}()
 	var req *http.Request
 	req, err = getWithContext(c.ctx, loginURL)
 	if err != nil {
@@ -42,6 +81,9 @@ func (c *punchClient) login(account *Account) (err error) {
 		return
 	}
 	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: %s", ErrUnexpectedStatus, res.Status)
+	}
 	f := &loginForm{}
 
 	{
@@ -50,7 +92,9 @@ func (c *punchClient) login(account *Account) (err error) {
 		const inputElement = "<input type=\"hidden\""
 		for !strings.HasPrefix(line, inputElement) {
 			line, err = scanLine(bufferReader)
-			if err != nil {
+			if err == io.EOF { // no hidden input
+				return ErrLoginPageChanged
+			} else if err != nil {
 				return
 			}
 		}
@@ -63,7 +107,7 @@ func (c *punchClient) login(account *Account) (err error) {
 		for {
 			v, err = elementParse(line)
 			if err != nil {
-				return
+				return fmt.Errorf("%w: %s", ErrLoginPageChanged, err.Error())
 			}
 			filler.fill(v.Key, v.Value)
 			line, _ = scanLine(bufferReader)
@@ -73,6 +117,9 @@ func (c *punchClient) login(account *Account) (err error) {
 		}
 	}
 	drainBody(res.Body)
+	if f.EncryptKey == "" {
+		return fmt.Errorf("%w: no pwdDefaultEncryptSalt", ErrLoginPageChanged)
+	}
 
 	f.Username = account.Username
 	f.Password, err = encryptAES(account.Password, f.EncryptKey)
@@ -95,14 +142,84 @@ func (c *punchClient) login(account *Account) (err error) {
 		return
 	}
 	c.httpClient.CheckRedirect = nil
+	if res.StatusCode == http.StatusFound { // redirect after login success
+		drainBody(res.Body)
+		return
+	}
+	var page []byte
+	page, err = io.ReadAll(io.LimitReader(res.Body, 1<<20))
 	drainBody(res.Body)
-
-	if res.StatusCode != http.StatusFound { // redirect after login success
-		err = ErrCouldNotLogin
+	if err != nil {
+		return
+	}
+	err = loginError(string(page))
+	if err == ErrNoLoginMessage && res.StatusCode != http.StatusOK && res.StatusCode != http.StatusUnauthorized {
+		err = fmt.Errorf("%w: %s", ErrUnexpectedStatus, res.Status) // e.g. 502 of the gateway
 	}
 	return
 }
 
+// loginError return the error of the login page returned after the login is rejected
+func loginError(page string) error {
+	msg := authMessage(page)
+	if msg == "" {
+		return ErrNoLoginMessage
+	}
+	lower := strings.ToLower(msg)
+	for _, m := range loginMessages {
+		for _, k := range m.keywords {
+			if strings.Contains(lower, k) {
+				return fmt.Errorf("%w: %s", m.err, msg)
+			}
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrCouldNotLogin, msg)
+}
+
+// authMessage return the text of the error message on the login page, e.g.
+// <span id="msg" class="auth_error">您提供的用户名或者密码有误</span>
+func authMessage(page string) string {
+	for _, attr := range []string{`id="msg"`, `class="auth_error"`} {
+		i := strings.Index(page, attr)
+		if i < 0 {
+			continue
+		}
+		text := page[i:]
+		if i = strings.IndexByte(text, '>'); i < 0 {
+			continue
+		}
+		text = text[i+1:]
+		if i = strings.IndexByte(text, '<'); i >= 0 {
+			text = text[:i]
+		}
+		if text = strings.TrimSpace(html.UnescapeString(text)); text != "" {
+			return text
+		}
+	}
+	return ""
+}
+
+// loginReason return the reason of the login error for the metrics
+func loginReason(err error) string {
+	switch {
+	case errors.Is(err, ErrWrongPassword):
+		return "wrong_password"
+	case errors.Is(err, ErrAccountLocked):
+		return "locked"
+	case errors.Is(err, ErrCaptchaRequired):
+		return "captcha"
+	case errors.Is(err, ErrPasswordExpired):
+		return "password_expired"
+	case errors.Is(err, ErrLoginPageChanged):
+		return "page_changed"
+	case errors.Is(err, ErrNoLoginMessage):
+		return "no_message"
+	case errors.Is(err, ErrCouldNotLogin):
+		return "unknown"
+	}
+	return "error" // e.g. the network error
+}
+
 func (c *punchClient) logout() error {
 	ctx := c.ctx
 	switch ctx.Err() {
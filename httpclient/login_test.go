@@ -0,0 +1,51 @@
+package httpclient
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoginError(t *testing.T) {
+	tests := []struct {
+		file string
+		msg  string // the message of authMessage
+		err  error
+	}{
+		{"wrong_password.html", "您提供的用户名或者密码有误", ErrWrongPassword},
+		{"wrong_password_warning.html", "密码错误，再输错 3 次账号将被锁定", ErrWrongPassword},
+		{"locked.html", "密码错误次数过多，账号已被锁定，请 10 分钟后再试", ErrAccountLocked},
+		{"captcha.html", "请输入验证码", ErrCaptchaRequired},
+		{"expired.html", "您的密码已过期，请修改密码后登录", ErrPasswordExpired},
+		{"retry_hint.html", "登录失败，可修改密码后重试", ErrCouldNotLogin},
+		{"busy.html", "系统繁忙，请稍后再试", ErrCouldNotLogin},
+		{"escaped.html", "用户名或密码错误 & 请检查大小写", ErrWrongPassword},
+		{"english_wrong_password.html", "The username or password you provided is incorrect.", ErrWrongPassword},
+		{"session_expired.html", "Your session has expired, please login again.", ErrCouldNotLogin},
+		{"no_message.html", "", ErrNoLoginMessage},
+		{"maintenance.html", "", ErrNoLoginMessage},
+	}
+	reasons := []error{ErrWrongPassword, ErrAccountLocked, ErrCaptchaRequired, ErrPasswordExpired, ErrLoginPageChanged, ErrNoLoginMessage}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			page := string(data)
+			if msg := authMessage(page); msg != tt.msg {
+				t.Errorf("authMessage = %q, want %q", msg, tt.msg)
+			}
+			err = loginError(page)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("loginError = %v, want %v", err, tt.err)
+			}
+			for _, reason := range reasons { // the unknown messages match no reason
+				if reason != tt.err && errors.Is(err, reason) {
+					t.Errorf("loginError = %v, also matches %v", err, reason)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,15 @@
+package httpclient
+
+import "report-stat/metrics"
+
+var (
+	loginAttempts = metrics.NewCounter("report_stat_login_attempts_total", "Number of the logins to the authserver.")
+	loginFailures = metrics.NewCounter("report_stat_login_failures_total", "Number of the failed logins to the authserver by the reason.", "reason")
+	loginDuration = metrics.NewHistogram("report_stat_login_duration_seconds", "Duration of the logins to the authserver.", nil)
+
+	formPages        = metrics.NewCounter("report_stat_form_pages_total", "Number of the pages of the form detail requested.")
+	formPageFailures = metrics.NewCounter("report_stat_form_page_failures_total", "Number of the pages of the form detail failed to request or decode.")
+	formPageDuration = metrics.NewHistogram("report_stat_form_page_duration_seconds", "Duration of the requests of the form detail pages.", nil)
+
+	renderDuration = metrics.NewHistogram("report_stat_render_duration_seconds", "Duration of rendering the images of an account.", nil)
+)
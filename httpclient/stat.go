@@ -3,6 +3,7 @@ package httpclient
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/url"
 	"time"
@@ -27,6 +28,8 @@ var (
 	symbolArray = [...]htmlSymbol{symbolString, symbolJSON}
 	//ErrCannotParseData cannot parse html data error
 	ErrCannotParseData = errors.New("data: parse error")
+	// ErrUnexpectedStatus the response status is not 200 OK
+	ErrUnexpectedStatus = errors.New("unexpected status")
 )
 
 // getFormSessionID 获取打卡系统的SessionID
@@ -66,13 +69,13 @@ func matchFunc(class []string) func(detail reportDetail) bool {
 	}
 }
 
-// getFormDetail 获取打卡表单详细信息
-func (c *punchClient) getFormDetail(wid string, key string, class ...string) (result detailArray, err error) {
+// getFormDetail 获取打卡表单详细信息, date format: 2006-01-02
+func (c *punchClient) getFormDetail(date string, wid string, key string, class ...string) (result detailArray, err error) {
 	// match := matchFunc(class)
 
 	form := queryForm{
 		Wid:      wid,
-		Date:     time.Now().In(timeZone).Format("2006-01-02"),
+		Date:     date,
 		Key:      key,
 		Page:     1,
 		PageSize: 200,
@@ -82,34 +85,46 @@ func (c *punchClient) getFormDetail(wid string, key string, class ...string) (re
 	}
 
 	for ; form.Page <= resData.MaxPage; form.Page++ {
-		var data url.Values
-		data, err = query.Values(form)
-		if err != nil {
-			return
-		}
-		var req *http.Request
-		req, err = postFormWithContext(c.ctx, "http://"+reportDomain+"/pdc/immediate/statisticsGrid", data)
-		if err != nil {
-			return
-		}
-		req.Header.Set("Accept", "application/json, text/javascript, */*; q=0.01") // accept json
-
-		var res *http.Response
-		if res, err = c.httpClient.Do(req); err != nil {
+		if err = c.getFormPage(&form, &resData); err != nil {
 			return
 		}
+		resData.Detail.clear()
 
-		decoder := json.NewDecoder(res.Body)
+		result = append(result, resData.Detail...)
+	}
+	return
+}
 
-		err = decoder.Decode(&resData)
-		drainBody(res.Body)
+// getFormPage request the page of the form detail and decode it into resData
+func (c *punchClient) getFormPage(form *queryForm, resData *queryResult) (err error) {
+	start := time.Now()
+	formPages.Inc()
+	defer func() {
+		formPageDuration.Observe(time.Since(start).Seconds())
 		if err != nil {
-			return
+			formPageFailures.Inc()
 		}
+	}()
+	data, err := query.Values(form)
+	if err != nil {
+		return
+	}
+	req, err := postFormWithContext(c.ctx, "http://"+reportDomain+"/pdc/immediate/statisticsGrid", data)
+	if err != nil {
+		return
+	}
+	req.Header.Set("Accept", "application/json, text/javascript, */*; q=0.01") // accept json
 
-		resData.Detail.clear()
-
-		result = append(result, resData.Detail...)
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return
+	}
+	defer drainBody(res.Body)
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: %s", ErrUnexpectedStatus, res.Status)
+	}
+	if err = json.NewDecoder(res.Body).Decode(resData); err != nil {
+		err = fmt.Errorf("%w: %s", ErrCannotParseData, err.Error())
 	}
 	return
 }
@@ -4,9 +4,16 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"image"
+	"image/png"
 	"net/http"
+	"time"
 )
 
+// ErrClassNotFound the class is not in the result
+var ErrClassNotFound = errors.New("result: class not found")
+
 // QueryParam query param struct
 type queryForm struct {
 	Wid        string `url:"wid"`
@@ -119,6 +126,18 @@ func (arr detailArray) classNames() []string {
 	return res
 }
 
+func (arr detailArray) students() []Student {
+	res := make([]Student, len(arr))
+	for i := range arr {
+		res[i] = Student{
+			ID:    arr[i].id(),
+			Name:  arr[i].name(),
+			Class: arr[i].class(),
+		}
+	}
+	return res
+}
+
 func (arr detailArray) filter(f func(detail reportDetail) bool) detailArray {
 	if len(arr) == 0 {
 		return arr
@@ -160,6 +179,52 @@ type punchClient struct {
 	jar        *cookieJar
 }
 
+// Student the student who has not reported
+type Student struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Class string `json:"class"`
+}
+
+// Form result of GetForm
+type Form struct {
+	Date     string    `json:"date"`     // date of the form, format: 2006-01-02
+	Classes  []string  `json:"classes"`  // classes of the students, sorted
+	Students []Student `json:"students"` // students who have not reported, sorted by class and id
+}
+
+// Result result of GetFormData
+type Result struct {
+	Date         string            // date of the form, format: 2006-01-02
+	Students     []Student         // students who have not reported, sorted by class and id
+	Data         []byte            // content of data.json
+	Status       []byte            // content of status.json
+	Images       map[string][]byte // webp images, key: class name
+	Remains      map[string]int    // number of students remaining, key: class name
+	Total        int               // number of students remaining
+	LastModified time.Time
+
+	pictures map[string]*image.RGBA
+}
+
+// PNG return the image of the class in PNG format
+func (r *Result) PNG(class string) ([]byte, error) {
+	img, ok := r.pictures[class]
+	if !ok {
+		return nil, ErrClassNotFound
+	}
+	buf := &bytes.Buffer{}
+	if err := png.Encode(buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Empty report whether all the students have reported
+func (r *Result) Empty() bool {
+	return r.Total == 0
+}
+
 // Account account info for login
 type Account struct {
 	Username string   `json:"username"`
@@ -1,21 +1,39 @@
 package httpclient
 
 import (
-	"bufio"
+	"bytes"
 	"encoding/json"
 	"os"
+	"path/filepath"
 )
 
-func storeJson(v interface{}, name string) error {
-	file, err := os.OpenFile(name, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+func marshalJson(v interface{}) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteFile write data to the file atomically, the data is written to a temporary
+// file in the same directory and then renamed, so the readers never see a partial file
+func WriteFile(name string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".*")
 	if err != nil {
 		return err
 	}
-	defer file.Close()
-	writer := bufio.NewWriter(file)
-	enc := json.NewEncoder(writer)
-	if err = enc.Encode(v); err != nil {
+	if _, err = tmp.Write(data); err == nil {
+		if err = tmp.Chmod(0644); err == nil {
+			err = tmp.Sync()
+		}
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
 		return err
 	}
-	return writer.Flush()
+	return os.Rename(tmp.Name(), name)
 }
@@ -0,0 +1,297 @@
+// Package logging write the leveled logs with key-value fields in text or json
+package logging
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Level the severity of the log
+type Level int
+
+const (
+	LevelDebug Level = iota - 1
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = map[Level]string{
+	LevelDebug: "debug",
+	LevelInfo:  "info",
+	LevelWarn:  "warn",
+	LevelError: "error",
+}
+
+// ErrInvalidLevel the level is not one of debug, info, warn and error
+var ErrInvalidLevel = errors.New("logging: invalid level")
+
+// ErrInvalidFormat the format is neither text nor json
+var ErrInvalidFormat = errors.New("logging: invalid format")
+
+func (l Level) String() string {
+	if s, ok := levelNames[l]; ok {
+		return s
+	}
+	return "level(" + strconv.Itoa(int(l)) + ")"
+}
+
+// UnmarshalText parse the level name, e.g. "warn"
+func (l *Level) UnmarshalText(text []byte) error {
+	s := strings.ToLower(string(text))
+	if s == "warning" {
+		s = "warn"
+	}
+	for k, v := range levelNames {
+		if v == s {
+			*l = k
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrInvalidLevel, text)
+}
+
+// Format the encoding of the logs
+type Format int
+
+const (
+	// FormatText one line per log: time, level, message and the fields as key=value
+	FormatText Format = iota
+	// FormatJSON one json object per line
+	FormatJSON
+)
+
+func (f Format) String() string {
+	if f == FormatJSON {
+		return "json"
+	}
+	return "text"
+}
+
+// UnmarshalText parse the format name, text or json
+func (f *Format) UnmarshalText(text []byte) error {
+	switch strings.ToLower(string(text)) {
+	case "text":
+		*f = FormatText
+	case "json":
+		*f = FormatJSON
+	default:
+		return fmt.Errorf("%w: %s", ErrInvalidFormat, text)
+	}
+	return nil
+}
+
+// output the destination and the settings shared by a logger and its children
+type output struct {
+	mu     sync.Mutex
+	w      io.Writer
+	level  Level
+	format Format
+}
+
+// Logger write the logs of the level and above, it is safe for concurrent use.
+// The methods Print, Printf, Println, Fatalf and Fatalln are compatible with log.Logger,
+// the messages are logged at info and error level.
+type Logger struct {
+	out    *output
+	fields []interface{} // key, value pairs added by With
+}
+
+// New create a logger writing the info logs in text to w
+func New(w io.Writer) *Logger {
+	return &Logger{out: &output{w: w}}
+}
+
+// SetOutput set the destination of the logger and its children
+func (l *Logger) SetOutput(w io.Writer) {
+	l.out.mu.Lock()
+	l.out.w = w
+	l.out.mu.Unlock()
+}
+
+// SetLevel set the minimum level of the logger and its children
+func (l *Logger) SetLevel(level Level) {
+	l.out.mu.Lock()
+	l.out.level = level
+	l.out.mu.Unlock()
+}
+
+// SetFormat set the format of the logger and its children
+func (l *Logger) SetFormat(format Format) {
+	l.out.mu.Lock()
+	l.out.format = format
+	l.out.mu.Unlock()
+}
+
+// Writer return the destination of the logger
+func (l *Logger) Writer() io.Writer {
+	l.out.mu.Lock()
+	defer l.out.mu.Unlock()
+	return l.out.w
+}
+
+// With return a logger adding the key-value pairs to each log
+func (l *Logger) With(kv ...interface{}) *Logger {
+	fields := make([]interface{}, 0, len(l.fields)+len(kv))
+	fields = append(append(fields, l.fields...), kv...)
+	return &Logger{out: l.out, fields: fields}
+}
+
+// Enabled report whether the logs of the level are written
+func (l *Logger) Enabled(level Level) bool {
+	l.out.mu.Lock()
+	defer l.out.mu.Unlock()
+	return level >= l.out.level
+}
+
+func (l *Logger) Debug(msg string, kv ...interface{}) { l.Log(LevelDebug, msg, kv...) }
+func (l *Logger) Info(msg string, kv ...interface{})  { l.Log(LevelInfo, msg, kv...) }
+func (l *Logger) Warn(msg string, kv ...interface{})  { l.Log(LevelWarn, msg, kv...) }
+func (l *Logger) Error(msg string, kv ...interface{}) { l.Log(LevelError, msg, kv...) }
+
+// Log write the message with the fields of the logger and kv at the level
+func (l *Logger) Log(level Level, msg string, kv ...interface{}) {
+	now := time.Now()
+	l.out.mu.Lock()
+	defer l.out.mu.Unlock()
+	if level < l.out.level {
+		return
+	}
+	b := &bytes.Buffer{}
+	if l.out.format == FormatJSON {
+		writeJSON(b, now, level, msg, l.fields, kv)
+	} else {
+		writeText(b, now, level, msg, l.fields, kv)
+	}
+	l.out.w.Write(b.Bytes())
+}
+
+func (l *Logger) Print(v ...interface{}) {
+	l.Info(strings.TrimSuffix(fmt.Sprint(v...), "\n"))
+}
+
+func (l *Logger) Printf(format string, v ...interface{}) {
+	l.Info(strings.TrimSuffix(fmt.Sprintf(format, v...), "\n"))
+}
+
+func (l *Logger) Println(v ...interface{}) {
+	l.Info(strings.TrimSuffix(fmt.Sprintln(v...), "\n"))
+}
+
+func (l *Logger) Fatalf(format string, v ...interface{}) {
+	l.Error(strings.TrimSuffix(fmt.Sprintf(format, v...), "\n"))
+	os.Exit(1)
+}
+
+func (l *Logger) Fatalln(v ...interface{}) {
+	l.Error(strings.TrimSuffix(fmt.Sprintln(v...), "\n"))
+	os.Exit(1)
+}
+
+// writeText write the log as: 2006/01/02 15:04:05 INFO message key=value ...
+func writeText(b *bytes.Buffer, t time.Time, level Level, msg string, fields ...[]interface{}) {
+	b.WriteString(t.Format("2006/01/02 15:04:05 "))
+	fmt.Fprintf(b, "%-5s ", strings.ToUpper(level.String()))
+	b.WriteString(msg)
+	for _, kv := range fields {
+		for i := 0; i < len(kv); i += 2 {
+			key, v := pair(kv, i)
+			b.WriteByte(' ')
+			b.WriteString(key)
+			b.WriteByte('=')
+			s := fmt.Sprint(value(v))
+			if needQuote(s) {
+				s = strconv.Quote(s)
+			}
+			b.WriteString(s)
+		}
+	}
+	b.WriteByte('\n')
+}
+
+// writeJSON write the log as a json object with the keys time, level, msg and the fields
+func writeJSON(b *bytes.Buffer, t time.Time, level Level, msg string, fields ...[]interface{}) {
+	b.WriteString(`{"time":`)
+	writeJSONValue(b, t.Format(time.RFC3339Nano))
+	b.WriteString(`,"level":`)
+	writeJSONValue(b, level.String())
+	b.WriteString(`,"msg":`)
+	writeJSONValue(b, msg)
+	for _, kv := range fields {
+		for i := 0; i < len(kv); i += 2 {
+			key, v := pair(kv, i)
+			b.WriteByte(',')
+			writeJSONValue(b, key)
+			b.WriteByte(':')
+			writeJSONValue(b, value(v))
+		}
+	}
+	b.WriteString("}\n")
+}
+
+// writeJSONValue write v as json, the value which can not be encoded is written as a string
+func writeJSONValue(b *bytes.Buffer, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprint(v))
+	}
+	b.Write(data)
+}
+
+// pair return the key and the value at i, the key is "!BADKEY" if the value is missing
+func pair(kv []interface{}, i int) (string, interface{}) {
+	if i+1 >= len(kv) {
+		return "!BADKEY", kv[i]
+	}
+	if key, ok := kv[i].(string); ok {
+		return key, kv[i+1]
+	}
+	return fmt.Sprint(kv[i]), kv[i+1]
+}
+
+// value convert the errors, the durations and the times to strings
+func value(v interface{}) interface{} {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case error:
+		return v.Error()
+	case time.Duration:
+		return v.String()
+	case time.Time:
+		return v.Format(time.RFC3339)
+	case fmt.Stringer:
+		return v.String()
+	}
+	return v
+}
+
+func needQuote(s string) bool {
+	if s == "" {
+		return true
+	}
+	for _, c := range s {
+		if c == '"' || c == '=' || unicode.IsSpace(c) || !unicode.IsPrint(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewID return a random id of 8 hex digits, e.g. the id of a run
+func NewID() string {
+	var b [4]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"time"
+
+	client "report-stat/httpclient"
+)
+
+// loginCheckCommand check the username and password of the accounts, return the exit code
+func loginCheckCommand(flagSet *flag.FlagSet, args []string) int {
+	name := flagSet.String("name", "", "only check the account with the `name`")
+	timeout := flagSet.Duration("timeout", 30*time.Second, "set the `timeout` of each login")
+	flagSet.Parse(args)
+
+	accounts, err := loadAccounts(accountPath)
+	if err == nil {
+		accounts, err = accounts.filter(*name)
+	}
+	if err != nil {
+		logger.Error(err.Error())
+		return exitError
+	}
+	code := exitOK
+	for _, account := range accounts {
+		plain, err := account.plain()
+		if err == nil {
+			err = client.LoginConfirm(context.Background(), plain, *timeout)
+		}
+		c := exitOK
+		switch {
+		case err == nil:
+			printf("%s: ok\n", account.Name)
+		case errors.Is(err, client.ErrCouldNotLogin):
+			printf("%s: login failed: %s\n", account.Name, err.Error())
+			c = exitLogin
+		default:
+			printf("%s: %s\n", account.Name, err.Error())
+			c = exitUpstream
+		}
+		if c > code {
+			code = c
+		}
+	}
+	return code
+}
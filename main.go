@@ -1,169 +1,300 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"embed"
 	"encoding/json"
-	"errors"
 	"flag"
 	"fmt"
-	"log"
+	"io/fs"
+	"net/http"
 	"os"
 	"os/signal"
 	"reflect"
-	"sort"
+	"sync"
 	"syscall"
 	"time"
 
+	"report-stat/history"
 	client "report-stat/httpclient"
+	"report-stat/logging"
+	"report-stat/schedule"
+	"report-stat/server"
 
 	"github.com/yin1999/healthreport/utils/email"
 )
 
-type timeSchedue struct {
-	Hour     uint8 `json:"hour"`
-	Minute   uint8 `json:"minute"`
-	SendMail bool  `json:"sendMail"`
-}
-
-type timeArray []timeSchedue
-
-var timeTable timeArray
+// timeTable the default time table loaded from timeTablePath
+var timeTable *schedule.Table
 
+// timeZone the time zone of the form
 var timeZone = time.FixedZone("CST", 8*3600)
 
+//go:embed www
+var www embed.FS
+
 var (
-	logger   = log.Default()
+	logger   = logging.New(os.Stderr)
 	emailCfg *email.Config
+	srv      *server.Server
+	store    *history.Store
 
-	maxAttempts   uint
-	accountPath   string
-	emailCfgPath  string
-	timeTablePath string
+	configPath         = os.Getenv("REPORT_STAT_CONFIG")
+	maxAttempts   uint = 4
+	accountPath        = "config/account.json"
+	emailCfgPath       = "config/email.json"
+	timeTablePath      = "config/timeTable.json"
+	listenAddr    string
+	historyDir         = "history"
+	retention     uint = 90
+	calendarPath  string
+	holidayTags   = schedule.DefaultTags
+	statePath     = "state.json"
+	catchUpWindow = 30 * time.Minute
+	refreshToken  = os.Getenv("REPORT_STAT_TOKEN")
+	shutdownGrace = 2 * time.Minute
+	staleAfter    time.Duration
 )
 
 func main() {
-	logger.Print("Starting app...\n")
-	defer logger.Print("Exit.\n")
+	logger.SetOutput(logRedactor)
+	os.Exit(dispatch(os.Args[1:]))
+}
+
+// runCommand run the scheduler until SIGINT or SIGTERM, reload on SIGHUP or
+// when the config files change
+func runCommand(flagSet *flag.FlagSet, args []string) int {
+	runFlags(flagSet)
+	flagSet.Parse(args)
+	visitFlags(flagSet)
+	if err := client.LoadFont(); err != nil {
+		logger.Fatalln(err)
+	}
+	cfg, err := loadApp()
+	if err != nil {
+		logger.Fatalln(err)
+	}
+
+	logger.Info("Starting app...")
+	defer logger.Info("Exit.")
 	c := make(chan os.Signal, 1)
-	signal.Notify(c, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM)
-	exit := false
-	for !exit {
+	signal.Notify(c, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGUSR1)
+	changed := watchConfig(watchInterval)
+	if listenAddr != "" {
+		if err := startServer(); err != nil {
+			logger.Fatalln(err)
+		}
+		defer func() {
+			ctx, cc := context.WithTimeout(context.Background(), 5*time.Second)
+			srv.Shutdown(ctx)
+			cc()
+		}()
+	}
+	for {
 		ctx, cc := context.WithCancel(context.Background())
-		go func(cc context.CancelFunc) {
-			switch <-c {
-			case syscall.SIGINT, syscall.SIGTERM:
-				exit = true
-				cc()
-			case syscall.SIGHUP:
-				logger.Print("Reloading app...\n")
-				cc()
-			}
-		}(cc)
-		err := app(ctx)
+		stop := make(chan struct{})
+		done := make(chan struct{})
+		next := make(chan *appConfig, 1)
+		go func() {
+			next <- control(c, changed, stop, done, cc)
+		}()
+		err := app(ctx, stop, cfg)
+		close(done)
 		cc()
 		if err != nil && err != context.Canceled {
-			log.Fatalln(err)
+			logger.Fatalln(err)
+		}
+		if cfg = <-next; cfg == nil {
+			return 0
 		}
 	}
 }
 
-func init() {
-	sort.Slice(timeTable, func(i, j int) bool {
-		return less(timeTable[i], timeTable[j])
-	})
-
-	flagSet := flag.NewFlagSet("parser", flag.ExitOnError)
-	flagSet.UintVar(&maxAttempts, "c", 4, "set max attepmts")
-	flagSet.StringVar(&accountPath, "a", "config/account.json", "set account file path")
-	flagSet.StringVar(&emailCfgPath, "e", "config/email.json", "set email file path")
-	flagSet.StringVar(&timeTablePath, "t", "config/timeTable.json", "set time table file path")
-	flagSet.Parse(os.Args[1:])
+// control handle the signals until the app is done, it closes stop to stop the
+// workers after the running tasks finish, and cancels the tasks if the grace
+// period is exceeded or the process is asked to exit twice.
+// On SIGHUP or the change of the config files, the new config is loaded first,
+// the app keeps running if it is invalid.
+// It returns the config to restart the app with, nil if the process should exit.
+func control(c <-chan os.Signal, changed <-chan struct{}, stop chan<- struct{}, done <-chan struct{}, cancel context.CancelFunc) (next *appConfig) {
+	exit, stopping := false, false
+	var grace <-chan time.Time
+	for {
+		select {
+		case sig := <-c:
+			switch sig {
+			case syscall.SIGUSR1:
+				logger.Info("Refreshing all accounts...")
+				go refreshAccounts(context.Background(), "", false)
+				continue
+			case syscall.SIGHUP:
+				if stopping {
+					continue
+				}
+				if next = reload(); next == nil {
+					continue
+				}
+			case syscall.SIGINT, syscall.SIGTERM:
+				if exit {
+					logger.Warn("Abort the running tasks")
+					cancel()
+					continue
+				}
+				exit, next = true, nil
+				logger.Info("Exiting after the running tasks finish", "grace", shutdownGrace)
+				timer := time.NewTimer(shutdownGrace)
+				defer timer.Stop()
+				grace = timer.C
+			}
+			if !stopping {
+				stopping = true
+				close(stop)
+			}
+		case <-changed:
+			if stopping {
+				continue
+			}
+			logger.Info("Config files changed")
+			if next = reload(); next != nil {
+				stopping = true
+				close(stop)
+			}
+		case <-grace:
+			logger.Warn("Grace period exceeded, abort the running tasks")
+			cancel()
+			grace = nil
+		case <-done:
+			return
+		}
+	}
 }
 
-func app(ctx context.Context) error {
-	var err error
-	emailCfg, err = email.LoadConfig(emailCfgPath)
+// reload load the new config, nil if it is invalid
+func reload() *appConfig {
+	cfg, err := loadApp()
 	if err != nil {
-		logger.Printf("Warning: email is not enabled, err:%s\n", err.Error())
+		logger.Error("Reload failed, keep the current config", "error", err)
+		return nil
 	}
-	account := &client.Account{}
-	err = loadJson(account, accountPath)
-	if err != nil {
-		logger.Fatalln(err)
+	logger.Info("Reloading app after the running tasks finish...")
+	return cfg
+}
+
+// startServer serve the embedded www and the latest form data on listenAddr
+func startServer() (err error) {
+	var static fs.FS
+	if static, err = fs.Sub(www, "www"); err != nil {
+		return
 	}
-	sort.Strings(account.Class)
-	err = loadJson(&timeTable, timeTablePath)
-	if err != nil {
-		logger.Fatalln(err)
+	if srv, err = server.New(listenAddr, static); err != nil {
+		return
 	}
-	sort.Sort(timeTable)
+	srv.HandleRefresh(refreshToken, refreshAccounts)
+	srv.HandleStatus(getStatus, checkHealth)
+	go func() {
+		if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+			logger.Fatalln(err)
+		}
+	}()
+	logger.Info("Serving", "addr", listenAddr)
+	return
+}
 
-	duration, send := nextTime()
-	timer := time.NewTimer(duration)
-	for {
-		select {
-		case <-timer.C:
-			if err = task(ctx, account, send); err != nil {
-				return err
+// app run the workers of cfg until stop is closed, the running tasks are canceled with ctx
+func app(ctx context.Context, stop <-chan struct{}, cfg *appConfig) error {
+	var err error
+	if cfg.settings != nil { // the workers are stopped
+		runningMu.Lock()
+		cfg.settings.apply()
+		runningMu.Unlock()
+	}
+	emailCfg, store = cfg.email, cfg.store
+
+	if slotState, err = loadState(statePath); err != nil {
+		logger.Warn("Load state failed", "error", err)
+	}
+
+	workers := cfg.workers
+	sites := make([]string, len(workers))
+	for i, w := range workers {
+		sites[i] = w.site
+	}
+	if srv != nil {
+		srv.SetSites(sites...)
+		srv.HandleRefresh(refreshToken, refreshAccounts)
+	}
+	runningMu.Lock()
+	for _, w := range workers { // keep the status across the reloads
+		for _, old := range running {
+			if old.Name == w.Name {
+				old.mu.Lock()
+				w.status = old.status
+				old.mu.Unlock()
+				w.status.attempt = 0
+				w.interrupted = old.interrupted
 			}
-		case <-ctx.Done():
-			timer.Stop()
-			return ctx.Err()
 		}
-		duration, send = nextTime()
-		timer.Reset(duration)
 	}
-}
+	running, runningConfig = workers, cfg
+	runningMu.Unlock()
 
-var ErrMaximumAttemptsExceeded = errors.New("serve: maximum attempts exceeded")
+	wg := sync.WaitGroup{}
+	wg.Add(len(workers))
+	for _, w := range workers {
+		go func(w *worker) {
+			defer wg.Done()
+			w.run(ctx, stop)
+		}(w)
+	}
+	wg.Wait()
+	return ctx.Err()
+}
 
-func task(ctx context.Context, account *client.Account, send bool) (err error) {
-	logger.Print("Start get form routine\n")
+// loadEmail load the email config from the config file or emailCfgPath
+func loadEmail() (*email.Config, error) {
+	var cfg *email.Config
+	var err error
+	if config != nil && config.Email != nil {
+		cfg = config.Email
+	} else if cfg, err = email.LoadConfig(emailCfgPath); err != nil {
+		return nil, err
+	}
+	if cfg.SMTP.Password != "" {
+		logRedactor.add(cfg.SMTP.Password)
+	}
+	return cfg, nil
+}
 
-	var timer *time.Timer
-	for count := uint(1); true; count++ {
-		logger.Print("Start getting form\n")
-		var empty bool
-		c, cc := context.WithTimeout(ctx, 50*time.Second)
-		empty, err = client.GetFormData(c, account)
-		cc()
-		switch err {
-		case nil:
-			logger.Print("get form finished\n")
-			if !empty && send && emailCfg != nil {
-				err = emailCfg.Send("form bot", "未填报名单提醒", fmt.Sprintf("未填报名单查看: <a href=\"https://%s/report-stat/\">链接</a>", account.Domain))
-				if err != nil {
-					logger.Printf("send email err: %s\n", err.Error())
-				} else {
-					logger.Print("send email success\n")
+// loadSchedules load the accounts with the time table and the calendar
+func loadSchedules() (accountList, error) {
+	accounts, err := loadAccounts(accountPath)
+	if err != nil {
+		return nil, err
+	}
+	loaded := make(map[*schedule.Table]bool)
+	for _, account := range accounts {
+		if account.TimeTable == nil {
+			if timeTable == nil && config != nil && config.TimeTable != nil {
+				timeTable = config.TimeTable
+			} else if timeTable == nil {
+				timeTable = &schedule.Table{}
+				if err = loadJson(timeTable, timeTablePath); err != nil {
+					return nil, err
 				}
 			}
-			return
-		case context.Canceled:
-			return
+			account.TimeTable = timeTable
 		}
-		if count >= maxAttempts {
-			break
+		if calendarPath == "" || loaded[account.TimeTable] {
+			continue
 		}
-		logger.Printf("Tried %d times. Retry after %v, err: %s\n", count, 5*time.Minute, err.Error())
-
-		if timer == nil {
-			timer = time.NewTimer(5 * time.Minute)
-		} else {
-			timer.Reset(5 * time.Minute)
-		}
-		select {
-		case <-timer.C:
-		case <-ctx.Done():
-			timer.Stop()
-			return ctx.Err()
+		table := account.TimeTable
+		if table.Calendar, err = schedule.LoadCalendar(calendarPath, holidayTags, table.Location); err != nil {
+			return nil, err
 		}
+		loaded[table] = true
 	}
-
-	if err := emailCfg.Send("form bot", "获取表单失败提示", "获取表单失败 err: "+err.Error()); err != nil {
-		logger.Printf("Send message failed, err: %s\n", err.Error())
-	}
-	return fmt.Errorf("maximum attempts: %d reached with error: %w", maxAttempts, err)
+	return accounts, nil
 }
 
 func loadJson(v interface{}, name string) error {
@@ -177,55 +308,20 @@ func loadJson(v interface{}, name string) error {
 	}
 	defer f.Close()
 	dec := json.NewDecoder(f)
-
-	return dec.Decode(v)
-}
-
-func (arr timeArray) Len() int {
-	return len(arr)
-}
-
-func (arr timeArray) Less(x, y int) bool {
-	return less(arr[x], arr[y])
-}
-
-func (arr timeArray) Swap(x, y int) {
-	arr[x], arr[y] = arr[y], arr[x]
-}
-
-func less(t1, t2 timeSchedue) bool {
-	if t1.Hour == t2.Hour {
-		return t1.Minute < t2.Minute
+	if strictJSON {
+		dec.DisallowUnknownFields()
 	}
-	return t1.Hour < t2.Hour
+	if err = dec.Decode(v); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
 }
 
-func nextTime() (time.Duration, bool) {
-	now := time.Now().In(timeZone)
-	hour, minute, _ := now.Clock()
-	year, month, day := now.Date()
-	n := timeSchedue{
-		Hour:   uint8(hour),
-		Minute: uint8(minute),
-	}
-	index := sort.Search(len(timeTable), func(i int) bool {
-		return less(n, timeTable[i])
-	})
-	if index < len(timeTable) {
-		if n == timeTable[index] { // not reachable
-			return 2 * time.Second, timeTable[index].SendMail // 2 second
-		}
-		nextTime := time.Date(year, month, day, int(timeTable[index].Hour), int(timeTable[index].Minute), 0, 0, timeZone)
-		d := nextTime.Sub(now)
-		if d < 2*time.Second {
-			d = 2 * time.Second
-		}
-		return d, timeTable[index].SendMail
+// unmarshalJson decode data into v, the unknown fields are rejected if strictJSON is set
+func unmarshalJson(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if strictJSON {
+		dec.DisallowUnknownFields()
 	}
-	nextTime := time.Date(year, month, day+1, int(timeTable[0].Hour), int(timeTable[0].Minute), 0, 0, timeZone)
-	d := nextTime.Sub(now)
-	if d < 2*time.Second {
-		d = 2 * time.Second
-	}
-	return d, timeTable[0].SendMail
+	return dec.Decode(v)
 }
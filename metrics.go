@@ -0,0 +1,15 @@
+package main
+
+import "report-stat/metrics"
+
+var (
+	fetchAttempts = metrics.NewCounter("report_stat_fetch_attempts_total", "Number of the attempts to get the form data.", "account")
+	fetchFailures = metrics.NewCounter("report_stat_fetch_failures_total", "Number of the failed attempts to get the form data by the error category.", "account", "category")
+	fetchDuration = metrics.NewHistogram("report_stat_fetch_duration_seconds", "Duration of the attempts to get the form data, including login and rendering.", nil, "account")
+
+	notificationsSent   = metrics.NewCounter("report_stat_notifications_sent_total", "Number of the messages sent.", "account")
+	notificationsFailed = metrics.NewCounter("report_stat_notifications_failed_total", "Number of the messages failed to send.", "account")
+
+	remainingStudents = metrics.NewGauge("report_stat_remaining_students", "Number of the students who have not reported at the last successful fetch.", "account", "class")
+	lastSuccess       = metrics.NewGauge("report_stat_last_success_timestamp_seconds", "Unix time of the last successful fetch.", "account")
+)
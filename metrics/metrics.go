@@ -0,0 +1,277 @@
+// Package metrics collect the counters, gauges and histograms and expose them
+// in the Prometheus text format
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Registry the set of the metrics exposed together
+type Registry struct {
+	mu      sync.Mutex
+	metrics []*metric
+}
+
+// Default the registry of the metrics created by NewCounter, NewGauge and NewHistogram
+var Default = &Registry{}
+
+// DefBuckets the default buckets of the histograms in seconds
+var DefBuckets = []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 25, 50}
+
+// metric a metric with the series of the label values
+type metric struct {
+	name, help, kind string
+	labels           []string
+	buckets          []float64 // upper bounds of the histogram buckets, +Inf excluded
+
+	mu     sync.Mutex
+	series map[string]*series // key: joined label values
+}
+
+type series struct {
+	labels []string
+	value  float64  // value of the counter or the gauge, sum of the histogram
+	counts []uint64 // counts of the histogram buckets, the last one is +Inf
+}
+
+func (r *Registry) register(name, help, kind string, labels []string, buckets []float64) *metric {
+	m := &metric{
+		name:    name,
+		help:    help,
+		kind:    kind,
+		labels:  labels,
+		buckets: buckets,
+		series:  make(map[string]*series),
+	}
+	if len(labels) == 0 { // exposed before the first observation
+		m.get(nil)
+	}
+	r.mu.Lock()
+	r.metrics = append(r.metrics, m)
+	r.mu.Unlock()
+	return m
+}
+
+// get return the series of the label values, created if not exists
+func (m *metric) get(values []string) *series {
+	if len(values) != len(m.labels) {
+		panic(fmt.Sprintf("metrics: %s: expect %d label values, got %d", m.name, len(m.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	s, ok := m.series[key]
+	if !ok {
+		s = &series{labels: append([]string(nil), values...)}
+		if m.kind == "histogram" {
+			s.counts = make([]uint64, len(m.buckets)+1)
+		}
+		m.series[key] = s
+	}
+	return s
+}
+
+// deletePrefix delete the series whose first label values are values
+func (m *metric) deletePrefix(values []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for key, s := range m.series {
+		match := true
+		for i, v := range values {
+			if i >= len(s.labels) || s.labels[i] != v {
+				match = false
+				break
+			}
+		}
+		if match {
+			delete(m.series, key)
+		}
+	}
+}
+
+// Counter a value which only increases, e.g. the number of the requests
+type Counter struct{ m *metric }
+
+// NewCounter create a counter with the label names in the default registry
+func NewCounter(name, help string, labels ...string) *Counter {
+	return &Counter{Default.register(name, help, "counter", labels, nil)}
+}
+
+// Inc add 1 to the series of the label values
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add add v to the series of the label values, v must not be negative
+func (c *Counter) Add(v float64, values ...string) {
+	if v < 0 {
+		panic("metrics: counter can not decrease")
+	}
+	c.m.mu.Lock()
+	c.m.get(values).value += v
+	c.m.mu.Unlock()
+}
+
+// Gauge a value which can go up and down, e.g. the number of the students remaining
+type Gauge struct{ m *metric }
+
+// NewGauge create a gauge with the label names in the default registry
+func NewGauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{Default.register(name, help, "gauge", labels, nil)}
+}
+
+// Set set the series of the label values to v
+func (g *Gauge) Set(v float64, values ...string) {
+	g.m.mu.Lock()
+	g.m.get(values).value = v
+	g.m.mu.Unlock()
+}
+
+// DeletePrefix delete the series whose first label values are values,
+// e.g. all the classes of an account
+func (g *Gauge) DeletePrefix(values ...string) {
+	g.m.deletePrefix(values)
+}
+
+// Histogram count the observations in the buckets, e.g. the latency of the requests
+type Histogram struct{ m *metric }
+
+// NewHistogram create a histogram with the bucket upper bounds in increasing order
+// and the label names in the default registry, DefBuckets is used if buckets is nil
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if buckets == nil {
+		buckets = DefBuckets
+	}
+	return &Histogram{Default.register(name, help, "histogram", labels, buckets)}
+}
+
+// Observe add v to the series of the label values
+func (h *Histogram) Observe(v float64, values ...string) {
+	i := sort.SearchFloat64s(h.m.buckets, v) // the first bucket with upper bound >= v
+	h.m.mu.Lock()
+	s := h.m.get(values)
+	s.counts[i]++
+	s.value += v
+	h.m.mu.Unlock()
+}
+
+// WriteTo write the metrics in the Prometheus text format
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	metrics := append([]*metric(nil), r.metrics...)
+	r.mu.Unlock()
+	cw := &countWriter{w: bufio.NewWriter(w)}
+	for _, m := range metrics {
+		m.write(cw)
+	}
+	if cw.err == nil {
+		cw.err = cw.w.Flush()
+	}
+	return cw.n, cw.err
+}
+
+// ServeHTTP serve the metrics in the Prometheus text format
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	h := w.Header()
+	h.Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	h.Set("Cache-Control", "no-store")
+	r.WriteTo(w)
+}
+
+func (m *metric) write(w *countWriter) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list := make([]*series, 0, len(m.series))
+	for _, s := range m.series {
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i].labels, list[j].labels
+		for k := range a {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return false
+	})
+	w.printf("# HELP %s %s\n# TYPE %s %s\n", m.name, escape(m.help, false), m.name, m.kind)
+	le := append(m.labels[:len(m.labels):len(m.labels)], "le") // the names of the bucket labels
+	for _, s := range list {
+		labels := formatLabels(m.labels, s.labels)
+		if m.kind != "histogram" {
+			w.printf("%s%s %s\n", m.name, labels, formatFloat(s.value))
+			continue
+		}
+		var count uint64
+		for i, n := range s.counts {
+			count += n
+			bound := math.Inf(1)
+			if i < len(m.buckets) {
+				bound = m.buckets[i]
+			}
+			values := append(s.labels[:len(s.labels):len(s.labels)], formatFloat(bound))
+			w.printf("%s_bucket%s %d\n", m.name, formatLabels(le, values), count)
+		}
+		w.printf("%s_sum%s %s\n", m.name, labels, formatFloat(s.value))
+		w.printf("%s_count%s %d\n", m.name, labels, count)
+	}
+}
+
+// formatLabels format the labels as {name="value",...}, empty if there is no label
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	b := &strings.Builder{}
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name)
+		b.WriteString(`="`)
+		b.WriteString(escape(values[i], true))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// escape escape the backslashes and the line feeds, and the double quotes of the label values
+func escape(s string, quote bool) string {
+	if quote {
+		return strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`).Replace(s)
+	}
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
+
+type countWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (w *countWriter) printf(format string, a ...interface{}) {
+	if w.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(w.w, format, a...)
+	w.n += int64(n)
+	w.err = err
+}
@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+func TestWriteTo(t *testing.T) {
+	r := &Registry{}
+	up := &Gauge{r.register("up", "Whether the daemon is up.", "gauge", nil, nil)}
+	requests := &Counter{r.register("requests_total", "The requests.\nBy \\path.", "counter", []string{"path", "code"}, nil)}
+	remains := &Gauge{r.register("remains", "The students remaining.", "gauge", []string{"account", "class"}, nil)}
+	latency := &Histogram{r.register("latency_seconds", "The latency.", "histogram", []string{"account"}, []float64{.1, 1, 10})}
+	r.register("empty_total", "No series before the first observation.", "counter", []string{"account"}, nil)
+
+	up.Set(1)
+	requests.Inc("/b", "200")
+	requests.Add(2, "/a", "500")
+	requests.Inc("/a", "200")
+	requests.Inc(`/q"x\y`+"\n", "200") // escaped
+	remains.Set(3, "b", "A")
+	remains.Set(1, "a", "B")
+	remains.Set(2, "a", "A")
+	remains.Set(5, "ab", "A") // not deleted by the prefix "a"
+	remains.DeletePrefix("a")
+	remains.Set(4, "a", "C")
+	for _, v := range []float64{.05, .1, .5, 2, 100} { // the bound is inclusive
+		latency.Observe(v, "a")
+	}
+	latency.Observe(1e-3, "b")
+
+	buf := &bytes.Buffer{}
+	n, err := r.WriteTo(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(buf.Len()) {
+		t.Errorf("WriteTo = %d, written %d bytes", n, buf.Len())
+	}
+	golden := filepath.Join("testdata", "metrics.txt")
+	if *update {
+		if err = os.WriteFile(golden, buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != string(want) {
+		t.Errorf("WriteTo:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestLabelValues(t *testing.T) {
+	r := &Registry{}
+	c := &Counter{r.register("c", "", "counter", []string{"a", "b"}, nil)}
+	defer func() {
+		if recover() == nil {
+			t.Error("Inc with the wrong number of the label values does not panic")
+		}
+	}()
+	c.Inc("x")
+}
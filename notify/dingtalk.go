@@ -0,0 +1,71 @@
+package notify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DingTalk DingTalk(钉钉) group robot, the image is not supported
+type DingTalk struct {
+	URL    string // https://oapi.dingtalk.com/robot/send?access_token=...
+	Secret string // secret of the signing security setting, optional
+}
+
+type dingtalkMessage struct {
+	MsgType  string            `json:"msgtype"`
+	Markdown *dingtalkMarkdown `json:"markdown"`
+}
+
+type dingtalkMarkdown struct {
+	Title string `json:"title"`
+	Text  string `json:"text"`
+}
+
+// Notify implement Notifier
+func (r *DingTalk) Notify(ctx context.Context, msg *Message) error {
+	text := "### " + msg.Title + "\n\n" + strings.ReplaceAll(msg.Text, "\n", "\n\n")
+	if msg.Link != "" {
+		text += "\n\n[查看名单](" + msg.Link + ")"
+	}
+	u, err := r.sign(time.Now())
+	if err != nil {
+		return err
+	}
+	res := &robotResponse{}
+	err = postJSON(ctx, u, &dingtalkMessage{
+		MsgType: "markdown",
+		Markdown: &dingtalkMarkdown{
+			Title: msg.Title,
+			Text:  text,
+		},
+	}, nil, res)
+	if err != nil {
+		return err
+	}
+	return res.err()
+}
+
+// sign append timestamp and sign to the url, sign = base64(HmacSHA256(secret, timestamp+"\n"+secret))
+func (r *DingTalk) sign(now time.Time) (string, error) {
+	if r.Secret == "" {
+		return r.URL, nil
+	}
+	u, err := url.Parse(r.URL)
+	if err != nil {
+		return "", err
+	}
+	timestamp := strconv.FormatInt(now.UnixNano()/int64(time.Millisecond), 10)
+	h := hmac.New(sha256.New, []byte(r.Secret))
+	h.Write([]byte(timestamp + "\n" + r.Secret))
+	q := u.Query()
+	q.Set("timestamp", timestamp)
+	q.Set("sign", base64.StdEncoding.EncodeToString(h.Sum(nil)))
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
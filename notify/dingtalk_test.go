@@ -0,0 +1,34 @@
+package notify
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+// The expected values are computed by the algorithm of the signing security
+// setting in the document of the DingTalk robot:
+// sign = urlEncode(base64(HmacSHA256(secret, timestamp+"\n"+secret))), timestamp in milliseconds
+func TestDingTalkSign(t *testing.T) {
+	r := &DingTalk{
+		URL:    "https://oapi.dingtalk.com/robot/send?access_token=token",
+		Secret: "SEC000000000000000000000",
+	}
+	got, err := r.sign(time.UnixMilli(1577262236757))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "https://oapi.dingtalk.com/robot/send?access_token=token&sign=xOJIB8eNoYWeLy26EMZOlc2RjOJbtPv9HYjm%2BE3uALU%3D&timestamp=1577262236757"
+	if got != want {
+		t.Errorf("sign = %s, want %s", got, want)
+	}
+	u, _ := url.Parse(got)
+	if s := u.Query().Get("sign"); s != "xOJIB8eNoYWeLy26EMZOlc2RjOJbtPv9HYjm+E3uALU=" {
+		t.Errorf("the decoded sign = %s", s)
+	}
+
+	r.Secret = ""
+	if got, err = r.sign(time.Now()); err != nil || got != r.URL {
+		t.Errorf("sign without the secret = %s, %v, want the url", got, err)
+	}
+}
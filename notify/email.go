@@ -0,0 +1,43 @@
+package notify
+
+import (
+	"context"
+	"html"
+	"strings"
+
+	"github.com/yin1999/healthreport/utils/email"
+)
+
+const mailNickName = "form bot"
+
+// Email send the message by email, the image is sent inline
+type Email struct {
+	Config *email.Config
+	To     []string // override the receivers of Config
+	Plain  bool     // send the text and the link without the image, like the email of the old versions
+}
+
+// Notify implement Notifier
+func (e *Email) Notify(ctx context.Context, msg *Message) error {
+	cfg := *e.Config
+	if len(e.To) != 0 {
+		cfg.To = e.To
+	}
+	body := msg.HTML
+	if body == "" {
+		body = strings.ReplaceAll(html.EscapeString(msg.Text), "\n", "<br>")
+		if msg.Link != "" {
+			body += `<br><a href="` + html.EscapeString(msg.Link) + `">链接</a>`
+		}
+	}
+	if len(msg.Image) == 0 || e.Plain {
+		return cfg.Send(mailNickName, msg.Title, body)
+	}
+	return sendMail(&cfg, &mail{
+		from:    mailNickName,
+		to:      cfg.To,
+		subject: msg.Title,
+		html:    body + `<br><img src="cid:image" alt="名单">`,
+		image:   msg.Image,
+	})
+}
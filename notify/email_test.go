@@ -0,0 +1,119 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/yin1999/healthreport/utils/email"
+)
+
+// fakeSMTP accept one session and send the data of the mail to the channel
+func fakeSMTP(t *testing.T) (port int, data <-chan string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	ch := make(chan string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(s string) { conn.Write([]byte(s + "\r\n")) }
+		reply("220 localhost")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch cmd := strings.ToUpper(strings.Fields(line + " x")[0]); cmd {
+			case "EHLO":
+				reply("250-localhost\r\n250 AUTH PLAIN")
+			case "AUTH":
+				reply("235 ok")
+			case "DATA":
+				reply("354 go ahead")
+				b := &strings.Builder{}
+				for {
+					line, err = r.ReadString('\n')
+					if err != nil || line == ".\r\n" {
+						break
+					}
+					b.WriteString(line)
+				}
+				ch <- b.String()
+				reply("250 ok")
+			case "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+	return l.Addr().(*net.TCPAddr).Port, ch
+}
+
+func TestEmail(t *testing.T) {
+	msg := &Message{Title: "未填报名单提醒", Text: "共 1 人未填报", Link: "https://example.com/report-stat/", Image: []byte("png")}
+	tests := []struct {
+		name   string
+		plain  bool
+		want   []string
+		absent []string
+	}{
+		{
+			name:   "plain",
+			plain:  true,
+			want:   []string{"Content-Type: text/html; charset=UTF-8", `共 1 人未填报<br><a href="https://example.com/report-stat/">链接</a>`},
+			absent: []string{"multipart", "cid:image"},
+		},
+		{
+			name: "inline image",
+			want: []string{"Content-Type: multipart/related", "Content-ID: <image>", "Content-Type: image/png", "To: b@example.com"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			port, data := fakeSMTP(t)
+			e := &Email{
+				Config: &email.Config{To: []string{"a@example.com"}, SMTP: email.SmtpConfig{Host: "127.0.0.1", Port: port, Username: "bot@example.com"}},
+				Plain:  test.plain,
+			}
+			if !test.plain {
+				e.To = []string{"b@example.com"}
+			}
+			if err := e.Notify(context.Background(), msg); err != nil {
+				t.Fatal(err)
+			}
+			got := <-data
+			for _, s := range test.want {
+				if !strings.Contains(got, s) {
+					t.Errorf("the mail does not contain %q:\n%s", s, got)
+				}
+			}
+			for _, s := range test.absent {
+				if strings.Contains(got, s) {
+					t.Errorf("the mail contains %q:\n%s", s, got)
+				}
+			}
+		})
+	}
+}
+
+func TestEmailInvalidAddress(t *testing.T) {
+	port, _ := fakeSMTP(t)
+	e := &Email{
+		Config: &email.Config{SMTP: email.SmtpConfig{Host: "127.0.0.1", Port: port, Username: "bot@example.com"}},
+		To:     []string{"a@example.com\r\nBcc: c@example.com"},
+	}
+	if err := e.Notify(context.Background(), &Message{Title: "t", Image: []byte("png")}); err == nil {
+		t.Error("the address containing CRLF is accepted")
+	}
+}
@@ -0,0 +1,75 @@
+package notify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"strconv"
+	"time"
+)
+
+// Feishu Feishu/Lark(飞书) group robot, the image is not supported
+// because it must be uploaded with the credentials of an app
+type Feishu struct {
+	URL    string // https://open.feishu.cn/open-apis/bot/v2/hook/...
+	Secret string // secret of the signature verification, optional
+}
+
+type feishuMessage struct {
+	Timestamp string      `json:"timestamp,omitempty"`
+	Sign      string      `json:"sign,omitempty"`
+	MsgType   string      `json:"msg_type"`
+	Card      *feishuCard `json:"card"`
+}
+
+type feishuCard struct {
+	Header   feishuHeader    `json:"header"`
+	Elements []feishuElement `json:"elements"`
+}
+
+type feishuHeader struct {
+	Title feishuText `json:"title"`
+}
+
+type feishuText struct {
+	Tag     string `json:"tag"`
+	Content string `json:"content"`
+}
+
+type feishuElement struct {
+	Tag     string `json:"tag"`
+	Content string `json:"content"`
+}
+
+// Notify implement Notifier, send an interactive card with markdown content
+func (r *Feishu) Notify(ctx context.Context, msg *Message) error {
+	text := msg.Text
+	if msg.Link != "" {
+		text += "\n[查看名单](" + msg.Link + ")"
+	}
+	m := &feishuMessage{
+		MsgType: "interactive",
+		Card: &feishuCard{
+			Header: feishuHeader{
+				Title: feishuText{Tag: "plain_text", Content: msg.Title},
+			},
+			Elements: []feishuElement{{Tag: "markdown", Content: text}},
+		},
+	}
+	if r.Secret != "" {
+		m.Timestamp, m.Sign = r.sign(time.Now())
+	}
+	res := &robotResponse{}
+	if err := postJSON(ctx, r.URL, m, nil, res); err != nil {
+		return err
+	}
+	return res.err()
+}
+
+// sign return timestamp and sign, sign = base64(HmacSHA256(timestamp+"\n"+secret, ""))
+func (r *Feishu) sign(now time.Time) (timestamp, sign string) {
+	timestamp = strconv.FormatInt(now.Unix(), 10)
+	h := hmac.New(sha256.New, []byte(timestamp+"\n"+r.Secret))
+	return timestamp, base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,20 @@
+package notify
+
+import (
+	"testing"
+	"time"
+)
+
+// The expected values are computed by the algorithm of the signature
+// verification in the document of the Feishu custom bot:
+// sign = base64(HmacSHA256(key: timestamp+"\n"+secret, data: empty)), timestamp in seconds
+func TestFeishuSign(t *testing.T) {
+	r := &Feishu{Secret: "demo"}
+	timestamp, sign := r.sign(time.Unix(1599360473, 0))
+	if timestamp != "1599360473" {
+		t.Errorf("timestamp = %s, want 1599360473", timestamp)
+	}
+	if want := "l1N0gAcBjdwBvGm1xMjOF0XSyaLRpR7tuO5dHfhAYc8="; sign != want {
+		t.Errorf("sign = %s, want %s", sign, want)
+	}
+}
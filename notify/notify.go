@@ -0,0 +1,120 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/yin1999/healthreport/utils/email"
+)
+
+var (
+	// ErrUnknownType the type of notifier is unknown
+	ErrUnknownType = errors.New("notify: unknown type")
+	// ErrNoURL the webhook url is empty
+	ErrNoURL = errors.New("notify: url is empty")
+	// ErrEmailDisabled email config is not loaded
+	ErrEmailDisabled = errors.New("notify: email is not enabled")
+)
+
+// Message notification message
+type Message struct {
+	Title string
+	Text  string // markdown text
+	HTML  string // html body for email, optional
+	Link  string // link of the web page, optional
+	Image []byte // PNG image, optional
+}
+
+// Notifier send the notification message
+type Notifier interface {
+	Notify(ctx context.Context, msg *Message) error
+}
+
+// Config config of a notifier
+type Config struct {
+	Type    string            `json:"type"`    // email, wecom, dingtalk, feishu or webhook
+	URL     string            `json:"url"`     // webhook url of the robot
+	Secret  string            `json:"secret"`  // signing secret, optional
+	To      []string          `json:"to"`      // email receivers, default: the receivers in email config
+	Headers map[string]string `json:"headers"` // extra headers of the generic webhook
+}
+
+// New create a notifier from cfg, mail is the email config used by the email notifier
+func New(cfg *Config, mail *email.Config) (Notifier, error) {
+	if cfg.Type == "email" {
+		if mail == nil {
+			return nil, ErrEmailDisabled
+		}
+		return &Email{Config: mail, To: cfg.To}, nil
+	}
+	if cfg.URL == "" {
+		return nil, ErrNoURL
+	}
+	switch cfg.Type {
+	case "wecom":
+		return &WeCom{URL: cfg.URL}, nil
+	case "dingtalk":
+		return &DingTalk{URL: cfg.URL, Secret: cfg.Secret}, nil
+	case "feishu":
+		return &Feishu{URL: cfg.URL, Secret: cfg.Secret}, nil
+	case "webhook":
+		return &Webhook{URL: cfg.URL, Secret: cfg.Secret, Headers: cfg.Headers}, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownType, cfg.Type)
+}
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// robotResponse response of the chat robots
+type robotResponse struct {
+	ErrCode int    `json:"errcode"` // wecom, dingtalk
+	ErrMsg  string `json:"errmsg"`
+	Code    int    `json:"code"` // feishu
+	Msg     string `json:"msg"`
+}
+
+func (r *robotResponse) err() error {
+	if r.ErrCode != 0 {
+		return fmt.Errorf("notify: robot error %d: %s", r.ErrCode, r.ErrMsg)
+	}
+	if r.Code != 0 {
+		return fmt.Errorf("notify: robot error %d: %s", r.Code, r.Msg)
+	}
+	return nil
+}
+
+// postJSON post v as json to url, the response is decoded into res if res is not nil
+func postJSON(ctx context.Context, url string, v interface{}, header http.Header, res interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		io.Copy(io.Discard, resp.Body)
+		return fmt.Errorf("notify: unexpected status: %s", resp.Status)
+	}
+	if res == nil {
+		_, err = io.Copy(io.Discard, resp.Body)
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(res)
+}
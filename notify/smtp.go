@@ -0,0 +1,87 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"mime"
+	"net/smtp"
+	"strings"
+	_ "unsafe" // go:linkname
+
+	"github.com/yin1999/healthreport/utils/email"
+)
+
+// mail an email with an inline image
+type mail struct {
+	from    string // nick name
+	to      []string
+	subject string
+	html    string
+	image   []byte // PNG image referenced by "cid:image" in html
+}
+
+// bytes return the message in MIME format
+func (m *mail) bytes(username string) []byte {
+	buf := &bytes.Buffer{}
+	buf.WriteString("From: " + mime.BEncoding.Encode("UTF-8", m.from) + " <" + username + ">\r\n")
+	buf.WriteString("To: " + strings.Join(m.to, ", ") + "\r\n")
+	buf.WriteString("Subject: " + mime.BEncoding.Encode("UTF-8", m.subject) + "\r\n")
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	boundary := randomBoundary()
+	buf.WriteString("Content-Type: multipart/related; boundary=\"" + boundary + "\"\r\n\r\n")
+	buf.WriteString("--" + boundary + "\r\n")
+	buf.WriteString("Content-Type: text/html; charset=UTF-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+	writeBase64(buf, []byte(m.html))
+	buf.WriteString("--" + boundary + "\r\n")
+	buf.WriteString("Content-Type: image/png\r\n")
+	buf.WriteString("Content-Transfer-Encoding: base64\r\n")
+	buf.WriteString("Content-ID: <image>\r\n")
+	buf.WriteString("Content-Disposition: inline; filename=\"image.png\"\r\n\r\n")
+	writeBase64(buf, m.image)
+	buf.WriteString("--" + boundary + "--\r\n")
+	return buf.Bytes()
+}
+
+// writeBase64 write data in base64 with lines of 76 characters
+func writeBase64(buf *bytes.Buffer, data []byte) {
+	s := base64.StdEncoding.EncodeToString(data)
+	for len(s) > 76 {
+		buf.WriteString(s[:76] + "\r\n")
+		s = s[76:]
+	}
+	buf.WriteString(s + "\r\n")
+}
+
+func randomBoundary() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// sendMail send the mail with the inline image by the SMTP client of
+// healthreport, whose Config.Send sends only a single html part
+func sendMail(cfg *email.Config, m *mail) error {
+	if len(cfg.To) == 0 {
+		return email.ErrNoReceiver
+	}
+	c, err := newClient(cfg.SMTP.Host, cfg.SMTP.Port, cfg.SMTP.TLS)
+	if err != nil {
+		return err
+	}
+	auth := smtp.PlainAuth("", cfg.SMTP.Username, cfg.SMTP.Password, cfg.SMTP.Host)
+	return deliver(c, auth, cfg.SMTP.Username, cfg.To, m.bytes(cfg.SMTP.Username))
+}
+
+// newClient dial the server on STARTTLS/TLS port, the same as Config.Send
+//
+//go:linkname newClient github.com/yin1999/healthreport/utils/email.newClient
+func newClient(host string, port int, TLS bool) (*smtp.Client, error)
+
+// deliver authenticate and send msg, the addresses containing CR or LF are
+// rejected, c is closed after sending
+//
+//go:linkname deliver github.com/yin1999/healthreport/utils/email.sendMail
+func deliver(c *smtp.Client, a smtp.Auth, from string, to []string, msg []byte) error
@@ -0,0 +1,52 @@
+package notify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+)
+
+// Webhook generic webhook, the message is posted as json:
+//
+//	{"title": "...", "text": "...", "link": "...", "image": "base64 of PNG"}
+//
+// When Secret is set, the header X-Signature is set to "sha256=" + hex(HmacSHA256(secret, body)).
+type Webhook struct {
+	URL     string
+	Secret  string
+	Headers map[string]string
+}
+
+type webhookMessage struct {
+	Title string `json:"title"`
+	Text  string `json:"text"`
+	Link  string `json:"link,omitempty"`
+	Image []byte `json:"image,omitempty"`
+}
+
+// Notify implement Notifier
+func (r *Webhook) Notify(ctx context.Context, msg *Message) error {
+	m := &webhookMessage{
+		Title: msg.Title,
+		Text:  msg.Text,
+		Link:  msg.Link,
+		Image: msg.Image,
+	}
+	header := make(http.Header, len(r.Headers)+1)
+	for k, v := range r.Headers {
+		header.Set(k, v)
+	}
+	if r.Secret != "" {
+		data, err := json.Marshal(m)
+		if err != nil {
+			return err
+		}
+		h := hmac.New(sha256.New, []byte(r.Secret))
+		h.Write(data)
+		header.Set("X-Signature", "sha256="+hex.EncodeToString(h.Sum(nil)))
+	}
+	return postJSON(ctx, r.URL, m, header, nil)
+}
@@ -0,0 +1,61 @@
+package notify
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// WeCom WeCom(企业微信) group robot
+type WeCom struct {
+	URL string // https://qyapi.weixin.qq.com/cgi-bin/webhook/send?key=...
+}
+
+type wecomMessage struct {
+	MsgType  string         `json:"msgtype"`
+	Markdown *wecomMarkdown `json:"markdown,omitempty"`
+	Image    *wecomImage    `json:"image,omitempty"`
+}
+
+type wecomMarkdown struct {
+	Content string `json:"content"`
+}
+
+type wecomImage struct {
+	Base64 string `json:"base64"`
+	MD5    string `json:"md5"`
+}
+
+// Notify implement Notifier, send a markdown message and then the image
+func (r *WeCom) Notify(ctx context.Context, msg *Message) error {
+	content := "**" + msg.Title + "**\n" + msg.Text
+	if msg.Link != "" {
+		content += "\n[查看名单](" + msg.Link + ")"
+	}
+	if err := r.send(ctx, &wecomMessage{
+		MsgType:  "markdown",
+		Markdown: &wecomMarkdown{Content: content},
+	}); err != nil {
+		return err
+	}
+	if len(msg.Image) == 0 {
+		return nil
+	}
+	sum := md5.Sum(msg.Image)
+	return r.send(ctx, &wecomMessage{
+		MsgType: "image",
+		Image: &wecomImage{
+			Base64: base64.StdEncoding.EncodeToString(msg.Image),
+			MD5:    hex.EncodeToString(sum[:]),
+		},
+	})
+}
+
+func (r *WeCom) send(ctx context.Context, m *wecomMessage) error {
+	res := &robotResponse{}
+	if err := postJSON(ctx, r.URL, m, nil, res); err != nil {
+		return err
+	}
+	return res.err()
+}
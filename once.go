@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"report-stat/history"
+	client "report-stat/httpclient"
+	"report-stat/schedule"
+)
+
+// exit codes of the once command, the largest one of the accounts is returned
+const (
+	exitOK        = 0 // all the students have reported
+	exitError     = 1 // invalid config or other errors
+	exitUsage     = 2 // invalid arguments
+	exitRemaining = 3 // some students have not reported
+	exitUpstream  = 4 // failed to get the form data
+	exitLogin     = 5 // failed to login
+)
+
+// onceExitCodes the help of the exit codes
+var onceExitCodes = fmt.Sprintf("exit codes: %d success, %d students remaining, %d upstream failure, %d login failure, %d other errors",
+	exitOK, exitRemaining, exitUpstream, exitLogin, exitError)
+
+// onceCommand run a single fetch/render/notify cycle of the accounts and exit,
+// return the exit code
+func onceCommand(flagSet *flag.FlagSet, args []string) int {
+	action := schedule.ActionFetch | schedule.ActionNotify
+	flagSet.Func("action", "set the `action` to run: fetch, notify, summary or the combinations like fetch+summary (default: fetch+notify)", func(s string) error {
+		return action.UnmarshalText([]byte(s))
+	})
+	attempts := flagSet.Uint("attempts", 1, "set max `attempts`, retry with the retry policy of the config file")
+	name := flagSet.String("name", "", "only run the account with the `name`")
+	flagSet.Parse(args)
+	if *attempts == 0 {
+		logger.Error("once: attempts must be positive")
+		return exitUsage
+	}
+
+	err := client.LoadFont()
+	if err != nil {
+		logger.Error(err.Error())
+		return exitError
+	}
+	if emailCfg, err = loadEmail(); err != nil {
+		logger.Warn("Email is not enabled", "error", err)
+	}
+	accounts, err := loadSchedules()
+	if err != nil {
+		logger.Error(err.Error())
+		return exitError
+	}
+	if accounts, err = accounts.filter(*name); err != nil {
+		logger.Error(err.Error())
+		return exitError
+	}
+	if historyDir != "" {
+		if store, err = history.Open(historyDir, int(retention)); err != nil {
+			logger.Error(err.Error())
+			return exitError
+		}
+	}
+
+	ctx, cc := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cc()
+	code := exitOK
+	for _, account := range accounts {
+		w, err := newWorker(account, emailCfg, len(accounts) != 1)
+		if err != nil {
+			logger.Error(err.Error(), "account", account.Name)
+			return exitError
+		}
+		c := exitOK
+		res, err := w.task(ctx, schedule.Slot{Time: time.Now().In(w.TimeTable.Location), Action: action}, *attempts)
+		switch {
+		case err == nil:
+			n := remaining(res)
+			fmt.Printf("%s: %d remaining\n", account.Name, n)
+			if n != 0 {
+				c = exitRemaining
+			}
+		case errors.Is(err, context.Canceled):
+			return exitError
+		default:
+			if action.Has(schedule.ActionNotify) {
+				w.notify(ctx, w.logger, w.failure(err))
+			}
+			switch errorCategory(err) {
+			case "login":
+				c = exitLogin
+			case "credential", "config": // e.g. the password file is missing or readable by others
+				c = exitError
+			default:
+				c = exitUpstream
+			}
+		}
+		if c > code {
+			code = c
+		}
+	}
+	return code
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"report-stat/schedule"
+)
+
+// previewCommand print the slots with a virtual clock, return the exit code
+func previewCommand(flagSet *flag.FlagSet, args []string) int {
+	days := flagSet.Uint("days", 7, "preview the slots in the next `days`")
+	from := flagSet.String("from", "", "set the start `time` of the virtual clock, format: 2006-01-02 15:04:05 (default: now)")
+	name := flagSet.String("name", "", "only preview the account with the `name`")
+	remains := flagSet.Int("remains", -1, "assume the `number` of students remaining for the adaptive fetches, -1 means unknown")
+	flagSet.Parse(args)
+
+	accounts, err := loadSchedules()
+	if err != nil {
+		logger.Error(err.Error())
+		return 1
+	}
+	for _, account := range accounts {
+		if *name != "" && account.Name != *name {
+			continue
+		}
+		table := account.TimeTable
+		start := time.Now()
+		if *from != "" {
+			if start, err = time.ParseInLocation("2006-01-02 15:04:05", *from, table.Location); err != nil {
+				logger.Error(err.Error())
+				return 2
+			}
+		}
+		fmt.Printf("[%s] %s\n", account.Name, table.Location)
+		previewSlots(table, start, start.AddDate(0, 0, int(*days)), *remains)
+	}
+	return 0
+}
+
+// previewSlots print the slots in [from, to) with a virtual clock,
+// the clock jumps to each slot as the scheduler does, the number of
+// students remaining after each fetch is assumed to be remains
+func previewSlots(table *schedule.Table, from, to time.Time, remains int) {
+	now := from
+	var paused *schedule.Event
+	var lastFetch time.Time
+	for {
+		slot := nextSlot(table, now, remains, lastFetch)
+		end := slot.Time
+		if slot.Time.IsZero() || !slot.Time.Before(to) {
+			end = to
+		}
+		if e := table.Calendar.Overlap(now, end); e != nil && e != paused {
+			fmt.Printf("  paused: %s\n", e)
+			paused = e
+		}
+		if end.Equal(to) {
+			return
+		}
+		t := slot.Time.In(table.Location)
+		fmt.Printf("  %s  %s\n", t.Format("2006-01-02 Mon 15:04:05"), slot.Action)
+		now, lastFetch = slot.Time, slot.Time
+	}
+}
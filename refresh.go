@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	client "report-stat/httpclient"
+	"report-stat/schedule"
+	"report-stat/server"
+)
+
+// ErrWorkerStopped the worker is stopped before the refresh runs, e.g. the app is reloading
+var ErrWorkerStopped = errors.New("worker: stopped")
+
+var (
+	runningMu     sync.RWMutex
+	running       []*worker  // the workers of the running app
+	runningConfig *appConfig // the config of the running app
+)
+
+// refresh an out-of-schedule run of the task requested manually,
+// the concurrent requests are coalesced into one run
+type refresh struct {
+	notify bool
+	done   chan struct{} // closed after the run
+	res    *client.Result
+	err    error
+}
+
+// requestRefresh request an immediate run of the task, the request joins the
+// pending or running refresh if any, notify is ignored if the refresh is running
+func (w *worker) requestRefresh(notify bool) *refresh {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.stopped {
+		r := &refresh{done: make(chan struct{}), err: ErrWorkerStopped}
+		close(r.done)
+		return r
+	}
+	if r := w.refresh; r != nil {
+		r.notify = r.notify || notify
+		return r
+	}
+	w.refresh = &refresh{notify: notify, done: make(chan struct{})}
+	select {
+	case w.trigger <- struct{}{}:
+	default:
+	}
+	return w.refresh
+}
+
+// runRefresh run the pending refresh once without retry
+func (w *worker) runRefresh(ctx context.Context) {
+	w.mu.Lock()
+	r := w.refresh
+	notify := r.notify
+	w.mu.Unlock()
+
+	action := schedule.ActionFetch
+	if notify {
+		action |= schedule.ActionNotify
+	}
+	w.logger.Info("Refresh requested", "action", action)
+	r.res, r.err = w.task(ctx, schedule.Slot{Time: time.Now().In(w.TimeTable.Location), Action: action}, 1)
+	w.lastRun = time.Now()
+
+	w.mu.Lock()
+	w.refresh = nil
+	w.mu.Unlock()
+	close(r.done)
+}
+
+// stop reject the pending refresh and the later requests
+func (w *worker) stop() {
+	w.mu.Lock()
+	w.stopped = true
+	if r := w.refresh; r != nil {
+		r.err = ErrWorkerStopped
+		close(r.done)
+		w.refresh = nil
+	}
+	w.mu.Unlock()
+}
+
+// refreshResult the result of a refresh reported by the api
+type refreshResult struct {
+	Name         string         `json:"name"`
+	OK           bool           `json:"ok"`
+	Error        string         `json:"error,omitempty"`
+	Date         string         `json:"date,omitempty"`
+	Total        int            `json:"total"`
+	Remains      map[string]int `json:"remains,omitempty"`
+	LastModified int64          `json:"lastModified,omitempty"`
+}
+
+// refreshAccounts refresh the account with name, all the accounts if name is empty,
+// and wait for the results
+func refreshAccounts(ctx context.Context, name string, notify bool) (interface{}, error) {
+	runningMu.RLock()
+	var workers []*worker
+	for _, w := range running {
+		if name == "" || w.Name == name {
+			workers = append(workers, w)
+		}
+	}
+	runningMu.RUnlock()
+	if len(workers) == 0 && name != "" {
+		return nil, fmt.Errorf("%w: account %s", server.ErrNotFound, name)
+	}
+
+	refreshes := make([]*refresh, len(workers))
+	for i, w := range workers {
+		refreshes[i] = w.requestRefresh(notify)
+	}
+	results := make([]refreshResult, len(workers))
+	for i, r := range refreshes {
+		results[i].Name = workers[i].Name
+		select {
+		case <-r.done:
+		case <-ctx.Done():
+			results[i].Error = ctx.Err().Error()
+			continue
+		}
+		if r.err != nil {
+			results[i].Error = r.err.Error()
+			continue
+		}
+		results[i].OK = true
+		results[i].Date = r.res.Date
+		results[i].Total = r.res.Total
+		results[i].Remains = r.res.Remains
+		results[i].LastModified = r.res.LastModified.Unix()
+	}
+	return results, nil
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"report-stat/history"
+	"report-stat/schedule"
+
+	"github.com/yin1999/healthreport/utils/email"
+)
+
+// watchInterval the interval to poll the config files, 0 to disable
+var watchInterval = 30 * time.Second
+
+// appConfig the config of the app loaded and validated before the app starts,
+// so that the running app is kept if the new config is invalid
+type appConfig struct {
+	accounts accountList
+	email    *email.Config
+	workers  []*worker
+	store    *history.Store // nil if the history is disabled
+	settings *configFile    // the changed settings of the config file applied on reload, nil if none
+	version  string         // hash of the config files
+	loaded   time.Time      // time the config is loaded
+}
+
+// restartSettings the settings of the config file which take effect after restart
+var restartSettings = map[string]bool{
+	"schedule.calendar":    true,
+	"schedule.holidayTags": true,
+	"schedule.watch":       true,
+	"server.listen":        true,
+	"render.font":          true,
+}
+
+// loadApp load and validate the config files of the app, the running app is not affected.
+// The loaded config file replaces config only if the config is valid.
+func loadApp() (cfg *appConfig, err error) {
+	file := config
+	if configPath != "" {
+		if file, err = loadConfig(configPath); err != nil {
+			return nil, err
+		}
+	}
+	prevConfig, prevTable := config, timeTable
+	config, timeTable = file, nil // used by loadEmail and loadSchedules
+	defer func() {
+		if err != nil {
+			config, timeTable = prevConfig, prevTable
+		}
+	}()
+	cfg = &appConfig{version: hashFiles(configFiles()), loaded: time.Now()}
+	if cfg.email, err = loadEmail(); errors.Is(err, os.ErrNotExist) {
+		logger.Warn("Email is not enabled", "error", err)
+		err = nil
+	} else if err != nil {
+		return nil, fmt.Errorf("%s: %w", emailCfgPath, err)
+	}
+	if cfg.accounts, err = loadSchedules(); err != nil {
+		return nil, err
+	}
+	checked := make(map[*schedule.Calendar]bool)
+	for _, account := range cfg.accounts {
+		if c := account.TimeTable.Calendar; !checked[c] {
+			checked[c] = true
+			for _, err := range c.Check() {
+				logger.Warn("Calendar", "account", account.Name, "error", err)
+			}
+		}
+		if _, err = account.password(); err != nil {
+			return nil, fmt.Errorf("account %s: password: %w", account.Name, err)
+		}
+	}
+	cfg.workers = make([]*worker, len(cfg.accounts))
+	for i, account := range cfg.accounts {
+		if cfg.workers[i], err = newWorker(account, cfg.email, len(cfg.accounts) != 1); err != nil {
+			return nil, fmt.Errorf("account %s: %w", account.Name, err)
+		}
+	}
+	var changed, restart []string
+	if file != nil && file != prevConfig {
+		cfg.settings, changed, restart = file.diff(prevConfig)
+	}
+	if cfg.store, err = openHistory(cfg.settings); err != nil {
+		return nil, fmt.Errorf("history: %w", err)
+	}
+	if len(changed) != 0 {
+		logger.Info("Config settings changed", "settings", strings.Join(changed, ","))
+	}
+	if len(restart) != 0 {
+		logger.Warn("Restart to apply the config settings", "settings", strings.Join(restart, ","))
+	}
+	return cfg, nil
+}
+
+// openHistory open the history store with the settings applied on reload,
+// nil if the history is disabled. The store is opened before the reload, so
+// that the running app is kept if the directory is not writable.
+func openHistory(settings *configFile) (*history.Store, error) {
+	dir, days := historyDir, retention
+	if settings != nil && settings.History.Dir != nil {
+		dir = *settings.History.Dir
+	}
+	if settings != nil && settings.History.Retention != nil {
+		days = *settings.History.Retention
+	}
+	if dir == "" {
+		return nil, nil
+	}
+	if err := checkWritable(dir); err != nil {
+		return nil, err
+	}
+	return history.Open(dir, int(days))
+}
+
+// diff return the settings of cfg which differ from old and take effect on
+// reload, with the json names of them, e.g. schedule.retry, and the names of
+// the changed settings which take effect after restart or are removed.
+// The unchanged settings and the settings set by the flags on the command line
+// are nil, so that the flags take precedence over the config file.
+// The accounts, the email and the time table are loaded by loadApp.
+func (cfg *configFile) diff(old *configFile) (res *configFile, changed, restart []string) {
+	if old == nil {
+		old = &configFile{}
+	}
+	res = &configFile{}
+	v, o, r := reflect.ValueOf(cfg).Elem(), reflect.ValueOf(old).Elem(), reflect.ValueOf(res).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		section := t.Field(i)
+		if section.Type.Kind() != reflect.Struct {
+			continue
+		}
+		for j := 0; j < section.Type.NumField(); j++ {
+			f := v.Field(i).Field(j)
+			if setFlags[section.Type.Field(j).Tag.Get("flag")] {
+				continue
+			}
+			if reflect.DeepEqual(f.Interface(), o.Field(i).Field(j).Interface()) {
+				continue
+			}
+			name := jsonName(section) + "." + jsonName(section.Type.Field(j))
+			if f.IsNil() || restartSettings[name] {
+				restart = append(restart, name)
+				continue
+			}
+			r.Field(i).Field(j).Set(f)
+			changed = append(changed, name)
+		}
+	}
+	return
+}
+
+func jsonName(f reflect.StructField) string {
+	return strings.Split(f.Tag.Get("json"), ",")[0]
+}
+
+// configFiles return the config files loaded by loadApp
+func configFiles() []string {
+	files := []string{accountPath, emailCfgPath, timeTablePath}
+	if configPath != "" {
+		files = append(files, configPath)
+	}
+	if calendarPath != "" {
+		files = append(files, calendarPath)
+	}
+	return files
+}
+
+// hashFiles return the first 12 hex digits of the sha256 of the names and the
+// contents of the files, the missing files are skipped
+func hashFiles(files []string) string {
+	h := sha256.New()
+	for _, name := range files {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00", name, len(data))
+		h.Write(data)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:12]
+}
+
+// stampFiles return the size and the modification time of the files,
+// the missing files are recorded as well
+func stampFiles(files []string) string {
+	b := &strings.Builder{}
+	for _, name := range files {
+		if info, err := os.Stat(name); err != nil {
+			fmt.Fprintf(b, "%s: missing\n", name)
+		} else {
+			fmt.Fprintf(b, "%s: %d %d\n", name, info.Size(), info.ModTime().UnixNano())
+		}
+	}
+	return b.String()
+}
+
+// watchConfig poll the config files every interval, a value is sent to the
+// returned channel when any of them changes. It never sends if interval is 0.
+func watchConfig(interval time.Duration) <-chan struct{} {
+	changed := make(chan struct{}, 1)
+	if interval <= 0 {
+		return changed
+	}
+	go func() {
+		last := stampFiles(configFiles())
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			stamp := stampFiles(configFiles())
+			if stamp == last {
+				continue
+			}
+			last = stamp
+			select {
+			case changed <- struct{}{}:
+			default: // a reload is pending
+			}
+		}
+	}()
+	return changed
+}
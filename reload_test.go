@@ -0,0 +1,39 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestConfigDiff(t *testing.T) {
+	parse := func(s string) *configFile {
+		cfg := &configFile{}
+		if err := unmarshalJson([]byte(s), cfg); err != nil {
+			t.Fatal(err)
+		}
+		return cfg
+	}
+	old := parse(`{"schedule":{"maxAttempts":3},"server":{"listen":":80"},"history":{"dir":"h1","retention":30}}`)
+	cfg := parse(`{"schedule":{"maxAttempts":5},"server":{"listen":":8080"},"history":{"dir":"h2","retention":30}}`)
+
+	defer func(flags map[string]bool) { setFlags = flags }(setFlags)
+	setFlags = map[string]bool{"history": true} // -history on the command line
+	res, changed, restart := cfg.diff(old)
+	if want := []string{"schedule.maxAttempts"}; !reflect.DeepEqual(changed, want) {
+		t.Errorf("changed = %v, want %v", changed, want)
+	}
+	if want := []string{"server.listen"}; !reflect.DeepEqual(restart, want) {
+		t.Errorf("restart = %v, want %v", restart, want)
+	}
+	if res.Schedule.MaxAttempts == nil || *res.Schedule.MaxAttempts != 5 {
+		t.Errorf("schedule.maxAttempts = %v, want 5", res.Schedule.MaxAttempts)
+	}
+	if res.History.Dir != nil || res.History.Retention != nil {
+		t.Errorf("history = %+v, want the flag and the unchanged setting kept", res.History)
+	}
+
+	setFlags = map[string]bool{}
+	if _, changed, _ = cfg.diff(old); !reflect.DeepEqual(changed, []string{"schedule.maxAttempts", "history.dir"}) {
+		t.Errorf("changed = %v without the flags", changed)
+	}
+}
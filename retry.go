@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	client "report-stat/httpclient"
+	"report-stat/schedule"
+)
+
+// ErrInvalidRetry the retry policy is invalid
+var ErrInvalidRetry = errors.New("retry: invalid policy")
+
+// retryPolicy the waits between the attempts of a task, the n-th wait is
+// Delay*Multiplier^(n-1) capped at MaxDelay, randomized by ±Jitter:
+//
+//	{"delay": "2m", "multiplier": 2, "maxDelay": "15m", "jitter": 0.1, "maxElapsed": "30m", "timeout": "50s"}
+//
+// The task gives up once the next attempt would start after MaxElapsed,
+// 0 for no limit. The errors which never succeed on retry, e.g. the wrong
+// password, fail the task immediately.
+type retryPolicy struct {
+	Delay      schedule.Duration `json:"delay"`
+	Multiplier float64           `json:"multiplier"`
+	MaxDelay   schedule.Duration `json:"maxDelay"`
+	Jitter     float64           `json:"jitter"`     // 0 to 1
+	MaxElapsed schedule.Duration `json:"maxElapsed"` // since the first attempt starts
+	Timeout    schedule.Duration `json:"timeout"`    // of each attempt
+}
+
+// retry the retry policy of the tasks, default: wait 5m between the attempts
+var retry = retryPolicy{
+	Delay:      schedule.Duration(5 * time.Minute),
+	Multiplier: 1,
+	Timeout:    schedule.Duration(50 * time.Second),
+}
+
+var (
+	jitterMu   sync.Mutex
+	jitterRand = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+// check check the values of the policy
+func (p *retryPolicy) check() error {
+	switch {
+	case p.Delay < 0 || p.MaxDelay < 0 || p.MaxElapsed < 0:
+		return fmt.Errorf("%w: negative duration", ErrInvalidRetry)
+	case p.Timeout <= 0:
+		return fmt.Errorf("%w: timeout must be positive", ErrInvalidRetry)
+	case p.Multiplier < 1:
+		return fmt.Errorf("%w: multiplier must be at least 1", ErrInvalidRetry)
+	case p.Jitter < 0 || p.Jitter > 1:
+		return fmt.Errorf("%w: jitter must be between 0 and 1", ErrInvalidRetry)
+	}
+	return nil
+}
+
+// delay return the wait after the attempt n failed, n starts from 1.
+// The wait grows without MaxDelay, so it is capped at the maximum duration.
+func (p *retryPolicy) delay(n uint) time.Duration {
+	d := float64(p.Delay) * math.Pow(p.Multiplier, float64(n-1))
+	if p.MaxDelay > 0 && d > float64(p.MaxDelay) {
+		d = float64(p.MaxDelay)
+	}
+	if p.Jitter > 0 {
+		jitterMu.Lock()
+		d *= 1 + p.Jitter*(2*jitterRand.Float64()-1)
+		jitterMu.Unlock()
+	}
+	if d >= math.MaxInt64 { // +Inf or overflow the conversion
+		return math.MaxInt64
+	}
+	return time.Duration(d)
+}
+
+// retryable report whether the task may succeed on retry,
+// the wrong password and the changed schema of the form never do
+func retryable(err error) bool {
+	switch errorCategory(err) {
+	case "parse", "credential", "config", "canceled":
+		return false
+	case "login": // the unknown messages like 系统繁忙 and the pages without a message may succeed on retry
+		return !errors.Is(err, client.ErrWrongPassword) && !errors.Is(err, client.ErrAccountLocked) &&
+			!errors.Is(err, client.ErrCaptchaRequired) && !errors.Is(err, client.ErrPasswordExpired)
+	}
+	return true
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"testing"
+	"time"
+
+	client "report-stat/httpclient"
+	"report-stat/schedule"
+	"report-stat/secret"
+)
+
+func TestRetryDelay(t *testing.T) {
+	minute := schedule.Duration(time.Minute)
+	tests := []struct {
+		name   string
+		policy retryPolicy
+		n      uint
+		want   time.Duration
+	}{
+		{"default", retry, 3, 5 * time.Minute},
+		{"first", retryPolicy{Delay: minute, Multiplier: 2}, 1, time.Minute},
+		{"exponential", retryPolicy{Delay: minute, Multiplier: 2}, 4, 8 * time.Minute},
+		{"max delay", retryPolicy{Delay: minute, Multiplier: 2, MaxDelay: 5 * minute}, 4, 5 * time.Minute},
+		{"no max delay", retryPolicy{Delay: minute, Multiplier: 2}, 100, math.MaxInt64},
+		{"infinity", retryPolicy{Delay: minute, Multiplier: 10}, 400, math.MaxInt64},
+		{"no delay", retryPolicy{Multiplier: 2}, 100, 0},
+	}
+	for _, test := range tests {
+		if got := test.policy.delay(test.n); got != test.want {
+			t.Errorf("%s: delay(%d) = %v, want %v", test.name, test.n, got, test.want)
+		}
+	}
+
+	p := retryPolicy{Delay: minute, Multiplier: 2, Jitter: 0.1}
+	for i := 0; i < 100; i++ {
+		if d := p.delay(2); d < 108*time.Second || d > 132*time.Second {
+			t.Fatalf("delay(2) with jitter 0.1 = %v, want 2m±10%%", d)
+		}
+	}
+	p = retryPolicy{Delay: minute, Multiplier: 2, Jitter: 1}
+	for i := 0; i < 100; i++ {
+		if d := p.delay(200); d < 0 {
+			t.Fatalf("delay(200) with jitter 1 = %v, want not negative", d)
+		}
+	}
+}
+
+func TestRetryCheck(t *testing.T) {
+	valid := retryPolicy{Delay: schedule.Duration(time.Minute), Multiplier: 2, Jitter: 0.1, Timeout: schedule.Duration(time.Minute)}
+	if err := valid.check(); err != nil {
+		t.Errorf("check(%+v) = %v", valid, err)
+	}
+	if err := retry.check(); err != nil {
+		t.Errorf("check of the default policy = %v", err)
+	}
+	tests := []struct {
+		name   string
+		modify func(p *retryPolicy)
+	}{
+		{"negative delay", func(p *retryPolicy) { p.Delay = -1 }},
+		{"negative max delay", func(p *retryPolicy) { p.MaxDelay = -1 }},
+		{"negative max elapsed", func(p *retryPolicy) { p.MaxElapsed = -1 }},
+		{"no timeout", func(p *retryPolicy) { p.Timeout = 0 }},
+		{"multiplier", func(p *retryPolicy) { p.Multiplier = 0.5 }},
+		{"negative jitter", func(p *retryPolicy) { p.Jitter = -0.1 }},
+		{"jitter", func(p *retryPolicy) { p.Jitter = 1.5 }},
+	}
+	for _, test := range tests {
+		p := valid
+		test.modify(&p)
+		if err := p.check(); !errors.Is(err, ErrInvalidRetry) {
+			t.Errorf("%s: check = %v, want %v", test.name, err, ErrInvalidRetry)
+		}
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{client.ErrWrongPassword, false},
+		{client.ErrAccountLocked, false},
+		{client.ErrCaptchaRequired, false},
+		{client.ErrPasswordExpired, false},
+		{client.ErrLoginPageChanged, false},
+		{fmt.Errorf("%w: data", client.ErrCannotParseData), false},
+		{fmt.Errorf("account a: password: %w", secret.ErrInvalidCiphertext), false},
+		{fmt.Errorf("open pw: %w", os.ErrNotExist), false},
+		{context.Canceled, false},
+		{ErrWorkerStopped, false},
+		{client.ErrNoLoginMessage, true},
+		{fmt.Errorf("%w: 系统繁忙", client.ErrCouldNotLogin), true},
+		{client.ErrCouldNotGetFormSession, true},
+		{fmt.Errorf("%w: 502 Bad Gateway", client.ErrUnexpectedStatus), true},
+		{context.DeadlineExceeded, true},
+		{errors.New("connection reset"), true},
+	}
+	for _, test := range tests {
+		if got := retryable(test.err); got != test.want {
+			t.Errorf("retryable(%v) = %v, want %v", test.err, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrInvalidAdaptive the adaptive config is invalid
+var ErrInvalidAdaptive = errors.New("schedule: invalid adaptive config")
+
+// Duration time.Duration in json format like "1h30m"
+type Duration time.Duration
+
+// UnmarshalText implement encoding.TextUnmarshaler
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	*d = Duration(v)
+	return err
+}
+
+// MarshalText implement encoding.TextMarshaler
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// Adaptive fetch more frequently as the daily deadline approaches
+// while many students remain:
+//
+//	{"deadline": "22:00", "rules": [{"before": "2h", "remains": 10, "interval": "20m"}]}
+//
+// A rule applies in the last Before of the deadline when at least Remains
+// students remain, the smallest interval of the applied rules is used.
+// Nothing is fetched once no student remains until the next day.
+type Adaptive struct {
+	Deadline string `json:"deadline"` // format: 15:04
+	Rules    []Rule `json:"rules"`
+
+	hour, minute int
+}
+
+// Rule rule of the adaptive polling
+type Rule struct {
+	Before   Duration `json:"before"`
+	Remains  int      `json:"remains"`
+	Interval Duration `json:"interval"`
+}
+
+// check parse the deadline and check the rules
+func (a *Adaptive) check() error {
+	if _, err := fmt.Sscanf(a.Deadline, "%d:%d", &a.hour, &a.minute); err != nil ||
+		a.hour < 0 || a.hour >= 24 || a.minute < 0 || a.minute >= 60 {
+		return fmt.Errorf("%w: deadline %q", ErrInvalidAdaptive, a.Deadline)
+	}
+	for _, r := range a.Rules {
+		if r.Before <= 0 || r.Interval < Duration(time.Minute) {
+			return fmt.Errorf("%w: before must be positive and interval must be at least 1m", ErrInvalidAdaptive)
+		}
+	}
+	return nil
+}
+
+// NextAdaptive return the next time to fetch by the adaptive rules after now,
+// remains is the number of students remaining at the last fetch, negative if unknown.
+// The zero time is returned if no rule applies before the next deadline.
+func (t *Table) NextAdaptive(now time.Time, remains int, lastFetch time.Time) time.Time {
+	a := t.Adaptive
+	if a == nil || len(a.Rules) == 0 {
+		return time.Time{}
+	}
+	now = now.In(t.Location)
+	year, month, day := now.Date()
+	deadline := time.Date(year, month, day, a.hour, a.minute, 0, 0, t.Location)
+	if !now.Before(deadline) {
+		deadline = deadline.AddDate(0, 0, 1)
+	}
+	cycleStart := deadline.AddDate(0, 0, -1)
+	if lastFetch.Before(cycleStart) { // the result of the last cycle is outdated
+		remains = -1
+		lastFetch = time.Time{}
+	}
+	if remains == 0 || len(t.entriesOn(deadline)) == 0 {
+		return time.Time{}
+	}
+
+	var next time.Time
+	for _, r := range a.Rules {
+		if remains >= 0 && remains < r.Remains {
+			continue
+		}
+		at := deadline.Add(-time.Duration(r.Before))
+		if v := lastFetch.Add(time.Duration(r.Interval)); v.After(at) {
+			at = v
+		}
+		if !at.After(now) {
+			at = now.Add(time.Second).Truncate(time.Second)
+		}
+		if !at.Before(deadline) || t.Calendar.Holiday(at) != nil {
+			continue
+		}
+		if next.IsZero() || at.Before(next) {
+			next = at
+		}
+	}
+	return next
+}
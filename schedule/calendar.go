@@ -0,0 +1,283 @@
+package schedule
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidEvent the event has no start time or the time is invalid
+	ErrInvalidEvent = errors.New("calendar: invalid event")
+	// ErrRecurringEvent the recurrence of the event is ignored, reported by Check
+	ErrRecurringEvent = errors.New("calendar: recurrence is not supported")
+	// ErrUnknownTimeZone the TZID of the event is unknown, reported by Check
+	ErrUnknownTimeZone = errors.New("calendar: unknown time zone")
+)
+
+// DefaultTags the default tags of the holiday events
+var DefaultTags = []string{"holiday", "假期", "寒假", "暑假"}
+
+// Event an event of the calendar, End is exclusive
+type Event struct {
+	Summary    string
+	Categories []string
+	Start      time.Time
+	End        time.Time
+}
+
+// String return the summary and the time range of the event
+func (e *Event) String() string {
+	const layout = "2006-01-02 15:04"
+	return e.Summary + " (" + e.Start.Format(layout) + " ~ " + e.End.Format(layout) + ")"
+}
+
+// Calendar the holidays in the school calendar, the slots in the holidays are suspended
+type Calendar struct {
+	Events []Event
+
+	problems []error
+}
+
+// LoadCalendar load the holiday events from an iCalendar(.ics) file.
+// An event is a holiday if one of its categories equals to a tag or
+// its summary contains a tag (case-insensitive). The floating time and the
+// time with an unknown TZID are in loc. Only the first occurrence of the
+// recurring events is used.
+func LoadCalendar(name string, tags []string, loc *time.Location) (*Calendar, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	c := &Calendar{}
+	events, problems, err := parseICS(f, loc)
+	if err != nil {
+		return nil, err
+	}
+	for i, e := range events {
+		if problems[i] != nil && isHoliday(&e, tags) {
+			c.problems = append(c.problems, problems[i]...)
+		}
+	}
+	for _, e := range events {
+		if isHoliday(&e, tags) {
+			c.Events = append(c.Events, e)
+		}
+	}
+	return c, nil
+}
+
+func isHoliday(e *Event, tags []string) bool {
+	summary := strings.ToLower(e.Summary)
+	for _, tag := range tags {
+		tag = strings.ToLower(tag)
+		if tag == "" {
+			continue
+		}
+		if strings.Contains(summary, tag) {
+			return true
+		}
+		for _, c := range e.Categories {
+			if strings.ToLower(c) == tag {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Check return the problems of the holiday events which do not stop the
+// calendar from working, e.g. the ignored recurrences and the unknown time zones
+func (c *Calendar) Check() []error {
+	if c == nil {
+		return nil
+	}
+	return c.problems
+}
+
+// Holiday return the holiday event at t, nil if t is not in any holiday
+func (c *Calendar) Holiday(t time.Time) *Event {
+	if c == nil {
+		return nil
+	}
+	for i := range c.Events {
+		if !t.Before(c.Events[i].Start) && t.Before(c.Events[i].End) {
+			return &c.Events[i]
+		}
+	}
+	return nil
+}
+
+// Overlap return the first holiday event overlapping [from, to), nil if not found
+func (c *Calendar) Overlap(from, to time.Time) *Event {
+	if c == nil {
+		return nil
+	}
+	var res *Event
+	for i := range c.Events {
+		e := &c.Events[i]
+		if e.Start.Before(to) && e.End.After(from) && (res == nil || e.Start.Before(res.Start)) {
+			res = e
+		}
+	}
+	return res
+}
+
+// parseICS parse the VEVENTs in the iCalendar data, and the problems of each event
+func parseICS(r io.Reader, loc *time.Location) (events []Event, problems [][]error, err error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	var (
+		e         *Event
+		depth     int // of the components in the event, e.g. VALARM
+		allDay    bool
+		hasEnd    bool
+		recurring bool
+		unknown   []string // the unknown TZIDs
+		invalid   error
+	)
+	for _, line := range lines {
+		name, params, value := splitProperty(line)
+		if tzid := params["TZID"]; e != nil && depth == 0 && tzid != "" && (name == "DTSTART" || name == "DTEND") {
+			if _, err := time.LoadLocation(tzid); err != nil {
+				if len(unknown) == 0 || unknown[len(unknown)-1] != tzid {
+					unknown = append(unknown, tzid)
+				}
+				delete(params, "TZID")
+			}
+		}
+		switch {
+		case name == "BEGIN" && value == "VEVENT":
+			e, depth, allDay, hasEnd, recurring, unknown, invalid = &Event{}, 0, false, false, false, nil, nil
+		case e == nil: // not in an event
+		case name == "BEGIN":
+			depth++
+		case depth > 0: // the properties of the nested components are ignored
+			if name == "END" {
+				depth--
+			}
+		case name == "END" && value == "VEVENT":
+			if invalid != nil || e.Start.IsZero() {
+				return nil, nil, ErrInvalidEvent
+			}
+			if !hasEnd {
+				e.End = e.Start
+				if allDay {
+					e.End = e.Start.AddDate(0, 0, 1)
+				}
+			}
+			var p []error
+			for _, tzid := range unknown {
+				p = append(p, fmt.Errorf("%w: %q of %s, %s is used", ErrUnknownTimeZone, tzid, e.Summary, loc))
+			}
+			if recurring {
+				p = append(p, fmt.Errorf("%w: only the first occurrence of %s is used", ErrRecurringEvent, e))
+			}
+			events, problems = append(events, *e), append(problems, p)
+			e = nil
+		case name == "RRULE" || name == "RDATE":
+			recurring = true
+		case name == "SUMMARY":
+			e.Summary = unescapeText(value)
+		case name == "CATEGORIES":
+			for _, c := range splitText(value) {
+				e.Categories = append(e.Categories, unescapeText(strings.TrimSpace(c)))
+			}
+		case name == "DTSTART":
+			e.Start, allDay, err = parseICSTime(value, params, loc)
+			if err != nil {
+				invalid = err
+			}
+		case name == "DTEND":
+			e.End, _, err = parseICSTime(value, params, loc)
+			if err != nil {
+				invalid = err
+			}
+			hasEnd = true
+		}
+	}
+	return
+}
+
+// unfold read the content lines, the folded lines are joined
+func unfold(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(line) != 0 && (line[0] == ' ' || line[0] == '\t') && len(lines) != 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+// splitProperty split "NAME;PARAM=VALUE:value"
+func splitProperty(line string) (name string, params map[string]string, value string) {
+	i := strings.IndexByte(line, ':')
+	if i < 0 {
+		return strings.ToUpper(line), nil, ""
+	}
+	value = line[i+1:]
+	parts := strings.Split(line[:i], ";")
+	name = strings.ToUpper(parts[0])
+	for _, p := range parts[1:] {
+		if kv := strings.SplitN(p, "=", 2); len(kv) == 2 {
+			if params == nil {
+				params = make(map[string]string)
+			}
+			params[strings.ToUpper(kv[0])] = strings.Trim(kv[1], `"`)
+		}
+	}
+	return
+}
+
+// parseICSTime parse DATE or DATE-TIME value
+func parseICSTime(value string, params map[string]string, loc *time.Location) (t time.Time, allDay bool, err error) {
+	if tzid := params["TZID"]; tzid != "" {
+		if loc, err = time.LoadLocation(tzid); err != nil {
+			return
+		}
+	}
+	switch {
+	case params["VALUE"] == "DATE" || len(value) == 8:
+		allDay = true
+		t, err = time.ParseInLocation("20060102", value, loc)
+	case strings.HasSuffix(value, "Z"):
+		t, err = time.Parse("20060102T150405Z", value)
+	default:
+		t, err = time.ParseInLocation("20060102T150405", value, loc)
+	}
+	return
+}
+
+var textReplacer = strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`)
+
+func unescapeText(s string) string {
+	return textReplacer.Replace(s)
+}
+
+// splitText split the list of the texts by the commas not escaped
+func splitText(s string) (list []string) {
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case ',':
+			list = append(list, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(list, s[start:])
+}
@@ -0,0 +1,183 @@
+package schedule
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseICS(t *testing.T) {
+	loc := time.FixedZone("CST", 8*3600)
+	tests := []struct {
+		name       string
+		event      string // the lines between BEGIN:VEVENT and END:VEVENT
+		summary    string
+		categories []string
+		start, end string // RFC 3339
+		problems   []error
+	}{
+		{
+			name:    "all-day",
+			event:   "SUMMARY:寒假\nDTSTART;VALUE=DATE:20240120\nDTEND;VALUE=DATE:20240226",
+			summary: "寒假",
+			start:   "2024-01-20T00:00:00+08:00",
+			end:     "2024-02-26T00:00:00+08:00",
+		},
+		{
+			name:    "all-day without end",
+			event:   "SUMMARY:清明节\nDTSTART:20240404",
+			summary: "清明节",
+			start:   "2024-04-04T00:00:00+08:00",
+			end:     "2024-04-05T00:00:00+08:00",
+		},
+		{
+			name:    "folded",
+			event:   "SUMMARY:Winter\n  holiday of\n\t the school\nDTSTART:20240120T080000\nDTEND:20240120T120000",
+			summary: "Winter holiday of the school",
+			start:   "2024-01-20T08:00:00+08:00",
+			end:     "2024-01-20T12:00:00+08:00",
+		},
+		{
+			name:       "escaped",
+			event:      `SUMMARY:假期\, 调休\; 见\Nnotes\\` + "\nCATEGORIES:holiday,a\\,b , exam\nDTSTART:20240501",
+			summary:    "假期, 调休; 见\nnotes\\",
+			categories: []string{"holiday", "a,b", "exam"},
+			start:      "2024-05-01T00:00:00+08:00",
+			end:        "2024-05-02T00:00:00+08:00",
+		},
+		{
+			name:    "UTC",
+			event:   "SUMMARY:x\nDTSTART:20240501T000000Z\nDTEND:20240501T010000Z",
+			summary: "x",
+			start:   "2024-05-01T08:00:00+08:00",
+			end:     "2024-05-01T09:00:00+08:00",
+		},
+		{
+			name:    "TZID",
+			event:   "SUMMARY:x\nDTSTART;TZID=Asia/Tokyo:20240501T090000\nDTEND;TZID=\"Asia/Tokyo\":20240501T100000",
+			summary: "x",
+			start:   "2024-05-01T08:00:00+08:00",
+			end:     "2024-05-01T09:00:00+08:00",
+		},
+		{
+			name:     "unknown TZID",
+			event:    "SUMMARY:x\nDTSTART;TZID=China Standard Time:20240501T090000\nDTEND;TZID=China Standard Time:20240501T100000",
+			summary:  "x",
+			start:    "2024-05-01T09:00:00+08:00",
+			end:      "2024-05-01T10:00:00+08:00",
+			problems: []error{ErrUnknownTimeZone},
+		},
+		{
+			name:     "recurring",
+			event:    "SUMMARY:x\nDTSTART:20240501\nRRULE:FREQ=YEARLY",
+			summary:  "x",
+			start:    "2024-05-01T00:00:00+08:00",
+			end:      "2024-05-02T00:00:00+08:00",
+			problems: []error{ErrRecurringEvent},
+		},
+		{
+			name: "VALARM",
+			event: "SUMMARY:暑假\nCATEGORIES:holiday\nDTSTART:20240710\n" +
+				"BEGIN:VALARM\nSUMMARY:提醒\nCATEGORIES:alarm\nDESCRIPTION:x\nTRIGGER;TZID=Unknown:-P1D\nDTSTART:20240101\nACTION:DISPLAY\nEND:VALARM\n" +
+				"DTEND:20240901",
+			summary:    "暑假",
+			categories: []string{"holiday"},
+			start:      "2024-07-10T00:00:00+08:00",
+			end:        "2024-09-01T00:00:00+08:00",
+		},
+		{
+			name:    "nested VALARM",
+			event:   "BEGIN:VALARM\nBEGIN:X-NESTED\nSUMMARY:a\nEND:X-NESTED\nSUMMARY:b\nEND:VALARM\nSUMMARY:x\nDTSTART:20240501",
+			summary: "x",
+			start:   "2024-05-01T00:00:00+08:00",
+			end:     "2024-05-02T00:00:00+08:00",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VEVENT\r\n" + strings.ReplaceAll(test.event, "\n", "\r\n") + "\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+			events, problems, err := parseICS(strings.NewReader(data), loc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(events) != 1 {
+				t.Fatalf("got %d events, want 1", len(events))
+			}
+			e := events[0]
+			if e.Summary != test.summary {
+				t.Errorf("summary = %q, want %q", e.Summary, test.summary)
+			}
+			if !reflect.DeepEqual(e.Categories, test.categories) {
+				t.Errorf("categories = %q, want %q", e.Categories, test.categories)
+			}
+			if got := e.Start.In(loc).Format(time.RFC3339); got != test.start {
+				t.Errorf("start = %s, want %s", got, test.start)
+			}
+			if got := e.End.In(loc).Format(time.RFC3339); got != test.end {
+				t.Errorf("end = %s, want %s", got, test.end)
+			}
+			if len(problems[0]) != len(test.problems) {
+				t.Fatalf("problems = %v, want %v", problems[0], test.problems)
+			}
+			for i, p := range problems[0] {
+				if !errors.Is(p, test.problems[i]) {
+					t.Errorf("problem %d = %v, want %v", i, p, test.problems[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseICSInvalid(t *testing.T) {
+	for _, event := range []string{
+		"SUMMARY:no start",
+		"SUMMARY:x\nDTSTART:2024-05-01",
+		"SUMMARY:x\nDTSTART:20240501\nDTEND:tomorrow",
+	} {
+		data := "BEGIN:VCALENDAR\nBEGIN:VEVENT\n" + event + "\nEND:VEVENT\nEND:VCALENDAR\n"
+		if _, _, err := parseICS(strings.NewReader(data), time.UTC); !errors.Is(err, ErrInvalidEvent) {
+			t.Errorf("parseICS(%q) = %v, want %v", event, err, ErrInvalidEvent)
+		}
+	}
+}
+
+func TestLoadCalendar(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "calendar.ics")
+	data := "BEGIN:VCALENDAR\n" +
+		"BEGIN:VTIMEZONE\nTZID:Asia/Shanghai\nBEGIN:STANDARD\nDTSTART:19700101T000000\nTZOFFSETFROM:+0800\nTZOFFSETTO:+0800\nEND:STANDARD\nEND:VTIMEZONE\n" +
+		"BEGIN:VEVENT\nSUMMARY:寒假\nDTSTART;VALUE=DATE:20240120\nDTEND;VALUE=DATE:20240226\nRRULE:FREQ=YEARLY\nEND:VEVENT\n" +
+		"BEGIN:VEVENT\nSUMMARY:期末考试\nDTSTART:20240110\nBEGIN:VALARM\nSUMMARY:假期\nEND:VALARM\nEND:VEVENT\n" +
+		"BEGIN:VEVENT\nSUMMARY:Labour Day\nCATEGORIES:HOLIDAY\nDTSTART:20240501\nDTEND:20240506\nEND:VEVENT\n" +
+		"END:VCALENDAR\n"
+	if err := os.WriteFile(name, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	loc := time.FixedZone("CST", 8*3600)
+	c, err := LoadCalendar(name, DefaultTags, loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var summaries []string
+	for _, e := range c.Events {
+		summaries = append(summaries, e.Summary)
+	}
+	if want := []string{"寒假", "Labour Day"}; !reflect.DeepEqual(summaries, want) {
+		t.Errorf("holidays = %q, want %q", summaries, want)
+	}
+	if p := c.Check(); len(p) != 1 || !errors.Is(p[0], ErrRecurringEvent) {
+		t.Errorf("Check = %v, want %v", p, ErrRecurringEvent)
+	}
+	if e := c.Holiday(time.Date(2024, 2, 1, 12, 0, 0, 0, loc)); e == nil || e.Summary != "寒假" {
+		t.Errorf("Holiday(2024-02-01) = %v", e)
+	}
+	if e := c.Holiday(time.Date(2024, 2, 26, 0, 0, 0, 0, loc)); e != nil { // End is exclusive
+		t.Errorf("Holiday(2024-02-26) = %v, want nil", e)
+	}
+	if e := c.Overlap(time.Date(2024, 4, 30, 0, 0, 0, 0, loc), time.Date(2024, 5, 2, 0, 0, 0, 0, loc)); e == nil || e.Summary != "Labour Day" {
+		t.Errorf("Overlap = %v", e)
+	}
+}
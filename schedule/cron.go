@@ -0,0 +1,229 @@
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCron the cron expression is invalid
+var ErrInvalidCron = errors.New("cron: invalid expression")
+
+// Spec parsed cron expression, each field is a bit set
+type Spec struct {
+	second, minute, hour, dom, month, dow uint64
+}
+
+type bounds struct {
+	min, max uint
+	names    map[string]uint
+}
+
+var (
+	secondBounds = bounds{0, 59, nil}
+	minuteBounds = bounds{0, 59, nil}
+	hourBounds   = bounds{0, 23, nil}
+	domBounds    = bounds{1, 31, nil}
+	monthBounds  = bounds{1, 12, map[string]uint{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	dowBounds = bounds{0, 7, map[string]uint{ // 0 and 7 are both sunday
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+// starBit set when the field is "*" or "?"
+const starBit = 1 << 63
+
+var descriptors = map[string]string{
+	"@yearly":   "0 0 0 1 1 *",
+	"@annually": "0 0 0 1 1 *",
+	"@monthly":  "0 0 0 1 * *",
+	"@weekly":   "0 0 0 * * 0",
+	"@daily":    "0 0 0 * * *",
+	"@midnight": "0 0 0 * * *",
+	"@hourly":   "0 0 * * * *",
+}
+
+// Parse parse the cron expression, the field of seconds is optional:
+//
+//	[second] minute hour day-of-month month day-of-week
+//
+// Each field accepts "*", "?", numbers, ranges "a-b", steps "*/n" or "a-b/n"
+// and lists separated by ",". Month and day-of-week accept names like
+// "jan" and "mon". Descriptors like "@daily" and "@hourly" are also accepted.
+func Parse(expr string) (*Spec, error) {
+	expr = strings.TrimSpace(expr)
+	if v, ok := descriptors[strings.ToLower(expr)]; ok {
+		expr = v
+	}
+	fields := strings.Fields(expr)
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		return nil, fmt.Errorf("%w: %q: expected 5 or 6 fields", ErrInvalidCron, expr)
+	}
+	s := &Spec{}
+	var err error
+	for i, f := range []struct {
+		v *uint64
+		b bounds
+	}{
+		{&s.second, secondBounds},
+		{&s.minute, minuteBounds},
+		{&s.hour, hourBounds},
+		{&s.dom, domBounds},
+		{&s.month, monthBounds},
+		{&s.dow, dowBounds},
+	} {
+		if *f.v, err = parseField(fields[i], f.b); err != nil {
+			return nil, fmt.Errorf("%w: %q: %s", ErrInvalidCron, expr, err.Error())
+		}
+	}
+	if s.dow&(1<<7) != 0 { // 7 is sunday
+		s.dow |= 1
+	}
+	return s, nil
+}
+
+func parseField(field string, b bounds) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		v, err := parseRange(part, b)
+		if err != nil {
+			return 0, err
+		}
+		bits |= v
+	}
+	return bits, nil
+}
+
+// parseRange parse "*", "a", "a-b", "*/n", "a/n" or "a-b/n"
+func parseRange(expr string, b bounds) (uint64, error) {
+	rangeAndStep := strings.SplitN(expr, "/", 2)
+	start, end, step := b.min, b.max, uint(1)
+	var extra uint64
+	switch r := rangeAndStep[0]; r {
+	case "*", "?":
+		if len(rangeAndStep) == 1 {
+			extra = starBit
+		}
+	default:
+		lowAndHigh := strings.SplitN(r, "-", 2)
+		var err error
+		if start, err = parseValue(lowAndHigh[0], b); err != nil {
+			return 0, err
+		}
+		switch {
+		case len(lowAndHigh) == 2:
+			if end, err = parseValue(lowAndHigh[1], b); err != nil {
+				return 0, err
+			}
+		case len(rangeAndStep) == 1:
+			end = start
+		}
+	}
+	if len(rangeAndStep) == 2 {
+		n, err := strconv.ParseUint(rangeAndStep[1], 10, 8)
+		if err != nil || n == 0 {
+			return 0, fmt.Errorf("invalid step: %q", expr)
+		}
+		step = uint(n)
+	}
+	if start > end {
+		return 0, fmt.Errorf("invalid range: %q", expr)
+	}
+	var bits uint64
+	for i := start; i <= end; i += step {
+		bits |= 1 << i
+	}
+	return bits | extra, nil
+}
+
+func parseValue(v string, b bounds) (uint, error) {
+	if n, ok := b.names[strings.ToLower(v)]; ok {
+		return n, nil
+	}
+	n, err := strconv.ParseUint(v, 10, 8)
+	if err != nil || uint(n) < b.min || uint(n) > b.max {
+		return 0, fmt.Errorf("value out of range [%d, %d]: %q", b.min, b.max, v)
+	}
+	return uint(n), nil
+}
+
+// Next return the first time matching the spec after t, in the location of t.
+// The times skipped by a daylight saving change never match, and the times
+// repeated by it match only once. The zero time is returned if no time
+// matches in five years.
+func (s *Spec) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Add(time.Second - time.Duration(t.Nanosecond()))
+	limit := t.AddDate(5, 0, 0)
+
+	// advance move t to next, or to the next hour if next is not after t,
+	// e.g. the midnight is skipped by a daylight saving change
+	advance := func(next time.Time) {
+		if !next.After(t) {
+			next = startOfHour(t).Add(time.Hour)
+		}
+		t = next.In(loc)
+	}
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			advance(time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc))
+			continue
+		}
+		if !s.dayMatches(t) {
+			advance(time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc))
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			// time.Date normalizes the hour in the gap back to the same hour
+			advance(startOfHour(t).Add(time.Hour))
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 || repeated(t) {
+			advance(t.Truncate(time.Minute).Add(time.Minute))
+			continue
+		}
+		if s.second&(1<<uint(t.Second())) == 0 {
+			advance(t.Add(time.Second))
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// startOfHour return the start of the hour of t on the wall clock
+func startOfHour(t time.Time) time.Time {
+	return t.Add(-time.Duration(t.Minute())*time.Minute - time.Duration(t.Second())*time.Second - time.Duration(t.Nanosecond()))
+}
+
+// repeated report whether the wall clock of t has been shown before,
+// i.e. t is in the second pass of the hour repeated by a daylight saving change
+func repeated(t time.Time) bool {
+	_, offset := t.Zone()
+	_, prev := t.Add(-time.Hour).Zone()
+	if prev <= offset {
+		return false
+	}
+	u := t.Add(-time.Duration(prev-offset) * time.Second)
+	return u.Hour() == t.Hour() && u.Minute() == t.Minute() && u.Day() == t.Day()
+}
+
+// dayMatches when both day-of-month and day-of-week are restricted,
+// the day matches if either of them matches
+func (s *Spec) dayMatches(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.dom&starBit != 0 || s.dow&starBit != 0 {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
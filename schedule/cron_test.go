@@ -0,0 +1,62 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSpecNext(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(loc *time.Location, value string) time.Time {
+		v, err := time.ParseInLocation("2006-01-02 15:04:05", value, loc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	tests := []struct {
+		name string
+		cron string
+		loc  *time.Location
+		from string
+		want string // RFC 3339, empty for the zero time
+	}{
+		{"minute", "30 15 * * *", DefaultLocation, "2024-05-01 10:00:00", "2024-05-01T15:30:00+08:00"},
+		{"after the time", "30 15 * * *", DefaultLocation, "2024-05-01 15:30:00", "2024-05-02T15:30:00+08:00"},
+		{"seconds", "*/15 * * * * *", DefaultLocation, "2024-05-01 12:00:07", "2024-05-01T12:00:15+08:00"},
+		{"month", "0 0 31 * *", DefaultLocation, "2024-04-01 00:00:00", "2024-05-31T00:00:00+08:00"},
+		{"leap day", "0 0 29 2 *", DefaultLocation, "2024-03-01 00:00:00", "2028-02-29T00:00:00+08:00"},
+		{"never", "0 0 30 2 *", DefaultLocation, "2024-01-01 00:00:00", ""},
+		{"dom or dow: weekday", "0 9 10 * fri", DefaultLocation, "2024-09-01 00:00:00", "2024-09-06T09:00:00+08:00"},
+		{"dom or dow: day of month", "0 9 10 * fri", DefaultLocation, "2024-09-07 00:00:00", "2024-09-10T09:00:00+08:00"},
+		{"dom and star dow", "0 9 10 * *", DefaultLocation, "2024-09-07 00:00:00", "2024-09-10T09:00:00+08:00"},
+		{"star dom and dow", "0 9 * * fri", DefaultLocation, "2024-09-07 00:00:00", "2024-09-13T09:00:00+08:00"},
+		{"sunday as 7", "0 9 * * 7", DefaultLocation, "2024-09-07 00:00:00", "2024-09-08T09:00:00+08:00"},
+		{"dst gap skipped", "30 2 * * *", newYork, "2024-03-09 12:00:00", "2024-03-11T02:30:00-04:00"},
+		{"dst gap hourly", "0 * * * *", newYork, "2024-03-10 01:30:00", "2024-03-10T03:00:00-04:00"},
+		{"dst overlap first pass", "30 1 * * *", newYork, "2024-11-03 00:00:00", "2024-11-03T01:30:00-04:00"},
+		{"dst overlap once", "30 1 * * *", newYork, "2024-11-03 01:30:00", "2024-11-04T01:30:00-05:00"},
+		{"dst overlap hourly", "0 * * * *", newYork, "2024-11-03 01:00:00", "2024-11-03T02:00:00-05:00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, err := Parse(tt.cron)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := spec.Next(at(tt.loc, tt.from))
+			if tt.want == "" {
+				if !got.IsZero() {
+					t.Errorf("Next = %v, want the zero time", got)
+				}
+				return
+			}
+			if got.Format(time.RFC3339) != tt.want {
+				t.Errorf("Next = %s, want %s", got.Format(time.RFC3339), tt.want)
+			}
+		})
+	}
+}
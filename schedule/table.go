@@ -0,0 +1,417 @@
+package schedule
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	_ "time/tzdata" // load the time zone without the system database
+)
+
+var (
+	// ErrEmptyTable the time table has no entry
+	ErrEmptyTable = errors.New("schedule: time table is empty")
+	// ErrUnknownAction the action is unknown
+	ErrUnknownAction = errors.New("schedule: unknown action")
+	// ErrOutOfRange hour or minute out of range
+	ErrOutOfRange = errors.New("schedule: hour or minute out of range")
+	// ErrUnknownWeekday the name of weekday is unknown
+	ErrUnknownWeekday = errors.New("schedule: unknown weekday")
+	// ErrInvalidDate the date is not in format 2006-01-02 or the range is reversed
+	ErrInvalidDate = errors.New("schedule: invalid date")
+	// ErrDuplicateWeekday the weekday is set more than once, e.g. "sat" and "saturday",
+	// the entries are merged, reported by Check
+	ErrDuplicateWeekday = errors.New("schedule: duplicate weekday")
+	// ErrDuplicateEntry the entries of the same cron expression, reported by Check
+	ErrDuplicateEntry = errors.New("schedule: duplicate entry")
+	// ErrOverlappingDates the date ranges overlap, the later one is partly ignored, reported by Check
+	ErrOverlappingDates = errors.New("schedule: overlapping date ranges")
+)
+
+// Strict reject the unknown fields of the time table, e.g. when validating the config
+var Strict bool
+
+// unmarshal decode data into v, the unknown fields are rejected if Strict is set
+func unmarshal(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if Strict {
+		dec.DisallowUnknownFields()
+	}
+	return dec.Decode(v)
+}
+
+const dateLayout = "2006-01-02"
+
+var weekdays = map[string]time.Weekday{
+	"sunday": time.Sunday, "sun": time.Sunday,
+	"monday": time.Monday, "mon": time.Monday,
+	"tuesday": time.Tuesday, "tue": time.Tuesday,
+	"wednesday": time.Wednesday, "wed": time.Wednesday,
+	"thursday": time.Thursday, "thu": time.Thursday,
+	"friday": time.Friday, "fri": time.Friday,
+	"saturday": time.Saturday, "sat": time.Saturday,
+}
+
+// DefaultLocation China Standard Time
+var DefaultLocation = time.FixedZone("CST", 8*3600)
+
+// Action the action of a slot, fetch is always included
+type Action uint8
+
+const (
+	// ActionFetch fetch the form data
+	ActionFetch Action = 1 << iota
+	// ActionNotify fetch the form data and notify the students remaining
+	ActionNotify
+	// ActionSummary fetch the form data and send the summary
+	ActionSummary
+)
+
+// Has report whether a contains action
+func (a Action) Has(action Action) bool {
+	return a&action != 0
+}
+
+// String return the names joined by "+", e.g. "fetch+notify"
+func (a Action) String() string {
+	names := []string{"fetch"}
+	if a.Has(ActionNotify) {
+		names = append(names, "notify")
+	}
+	if a.Has(ActionSummary) {
+		names = append(names, "summary")
+	}
+	return strings.Join(names, "+")
+}
+
+// MarshalText implement encoding.TextMarshaler
+func (a Action) MarshalText() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalText implement encoding.TextUnmarshaler,
+// accept "fetch", "notify", "summary" and the combinations like "fetch+notify"
+func (a *Action) UnmarshalText(text []byte) error {
+	*a = ActionFetch
+	for _, name := range strings.Split(string(text), "+") {
+		switch strings.TrimSpace(name) {
+		case "fetch", "":
+		case "notify":
+			*a |= ActionNotify
+		case "summary":
+			*a |= ActionSummary
+		default:
+			return fmt.Errorf("%w: %q", ErrUnknownAction, name)
+		}
+	}
+	return nil
+}
+
+// Entry an entry of the time table
+type Entry struct {
+	Cron   string `json:"cron"`
+	Action Action `json:"action"`
+
+	spec *Spec
+}
+
+// UnmarshalJSON accept both the cron entry and the legacy entry:
+//
+//	{"cron": "0 15 * * *", "action": "notify"}
+//	{"hour": 15, "minute": 0, "sendMail": true}
+func (e *Entry) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Cron     string  `json:"cron"`
+		Action   *Action `json:"action"`
+		Hour     *int    `json:"hour"`
+		Minute   *int    `json:"minute"`
+		SendMail bool    `json:"sendMail"`
+	}
+	if err := unmarshal(data, &v); err != nil {
+		return err
+	}
+	entry := Entry{Cron: v.Cron, Action: ActionFetch}
+	if v.Action != nil {
+		entry.Action = *v.Action
+	}
+	if v.Cron == "" { // legacy entry
+		var hour, minute int
+		if v.Hour != nil {
+			hour = *v.Hour
+		}
+		if v.Minute != nil {
+			minute = *v.Minute
+		}
+		if hour < 0 || hour >= 24 || minute < 0 || minute >= 60 {
+			return ErrOutOfRange
+		}
+		entry.Cron = fmt.Sprintf("%d %d * * *", minute, hour)
+		if v.SendMail {
+			entry.Action |= ActionNotify
+		}
+	}
+	var err error
+	if entry.spec, err = Parse(entry.Cron); err != nil {
+		return err
+	}
+	*e = entry
+	return nil
+}
+
+// Table the time table
+type Table struct {
+	Location *time.Location
+	Entries  []Entry                  // the default entries
+	Weekdays map[time.Weekday][]Entry // replace the default entries on the weekday
+	Dates    []DateRange              // replace the entries in the date range, the first matched range is used
+	Calendar *Calendar                // the slots in the holidays are suspended, optional
+	Adaptive *Adaptive                // fetch more frequently near the deadline, optional
+
+	duplicates map[time.Weekday][]string // the names of the weekdays set more than once
+}
+
+// DateRange replace or suppress the entries in the date range
+type DateRange struct {
+	From     string  `json:"from"` // first date, format: 2006-01-02
+	To       string  `json:"to"`   // last date, format: 2006-01-02, default: From
+	Entries  []Entry `json:"entries"`
+	Suppress bool    `json:"suppress"` // no slot in the date range, Entries is ignored
+}
+
+// Slot a time to run the action
+type Slot struct {
+	Time   time.Time
+	Action Action
+}
+
+// String return the time of the slot, format: 15:04
+func (s Slot) String() string {
+	return s.Time.Format("15:04")
+}
+
+// UnmarshalJSON accept both a list of entries (the legacy time table)
+// and an object:
+//
+//	{
+//		"timeZone": "Asia/Shanghai",
+//		"entries": [...],
+//		"weekdays": {"saturday": [...], "sunday": []},
+//		"dates": [{"from": "2022-01-10", "to": "2022-02-20", "suppress": true}],
+//		"adaptive": {"deadline": "22:00", "rules": [...]}
+//	}
+//
+// The default time zone is China Standard Time.
+func (t *Table) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	table := Table{Location: DefaultLocation}
+	if len(data) != 0 && data[0] == '[' {
+		if err := unmarshal(data, &table.Entries); err != nil {
+			return err
+		}
+	} else {
+		var v struct {
+			TimeZone string             `json:"timeZone"`
+			Entries  []Entry            `json:"entries"`
+			Weekdays map[string][]Entry `json:"weekdays"`
+			Dates    []DateRange        `json:"dates"`
+			Adaptive *Adaptive          `json:"adaptive"`
+		}
+		if err := unmarshal(data, &v); err != nil {
+			return err
+		}
+		if v.TimeZone != "" {
+			loc, err := time.LoadLocation(v.TimeZone)
+			if err != nil {
+				return err
+			}
+			table.Location = loc
+		}
+		table.Entries = v.Entries
+		if len(v.Weekdays) != 0 {
+			table.Weekdays = make(map[time.Weekday][]Entry, len(v.Weekdays))
+			names := make(map[time.Weekday][]string, len(v.Weekdays))
+			for name, entries := range v.Weekdays {
+				day, ok := weekdays[strings.ToLower(name)]
+				if !ok {
+					return fmt.Errorf("%w: %q", ErrUnknownWeekday, name)
+				}
+				table.Weekdays[day] = append(table.Weekdays[day], entries...)
+				names[day] = append(names[day], name)
+			}
+			for day, list := range names {
+				if len(list) > 1 {
+					sort.Strings(list)
+					if table.duplicates == nil {
+						table.duplicates = make(map[time.Weekday][]string)
+					}
+					table.duplicates[day] = list
+				}
+			}
+		}
+		for i := range v.Dates {
+			r := &v.Dates[i]
+			if r.To == "" {
+				r.To = r.From
+			}
+			from, err := time.Parse(dateLayout, r.From)
+			if err != nil {
+				return fmt.Errorf("%w: %q", ErrInvalidDate, r.From)
+			}
+			to, err := time.Parse(dateLayout, r.To)
+			if err != nil || to.Before(from) {
+				return fmt.Errorf("%w: %q", ErrInvalidDate, r.To)
+			}
+		}
+		table.Dates = v.Dates
+		if v.Adaptive != nil {
+			if err := v.Adaptive.check(); err != nil {
+				return err
+			}
+			table.Adaptive = v.Adaptive
+		}
+	}
+	if !table.hasEntry() {
+		return ErrEmptyTable
+	}
+	*t = table
+	return nil
+}
+
+// hasEntry report whether the table has any entry
+func (t *Table) hasEntry() bool {
+	if len(t.Entries) != 0 {
+		return true
+	}
+	for _, entries := range t.Weekdays {
+		if len(entries) != 0 {
+			return true
+		}
+	}
+	for _, r := range t.Dates {
+		if !r.Suppress && len(r.Entries) != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Check return the problems which do not stop the table from working,
+// e.g. the duplicate entries and the overlapping date ranges
+func (t *Table) Check() (errs []error) {
+	duplicate := func(where string, entries []Entry) {
+		seen := make(map[string]bool, len(entries))
+		for _, e := range entries {
+			cron := strings.Join(strings.Fields(e.Cron), " ")
+			if seen[cron] {
+				errs = append(errs, fmt.Errorf("%w: %q in %s", ErrDuplicateEntry, e.Cron, where))
+			}
+			seen[cron] = true
+		}
+	}
+	duplicate("entries", t.Entries)
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if names := t.duplicates[day]; names != nil {
+			errs = append(errs, fmt.Errorf("%w: %s set by %q, the entries are merged", ErrDuplicateWeekday, strings.ToLower(day.String()), names))
+		}
+		duplicate(strings.ToLower(day.String()), t.Weekdays[day])
+	}
+	for i, r := range t.Dates {
+		duplicate(r.From+"~"+r.To, r.Entries)
+		for _, prev := range t.Dates[:i] {
+			if r.From <= prev.To && prev.From <= r.To {
+				errs = append(errs, fmt.Errorf("%w: %s~%s and %s~%s", ErrOverlappingDates, prev.From, prev.To, r.From, r.To))
+			}
+		}
+	}
+	return
+}
+
+// entriesOn return the entries of the date, the date ranges take
+// precedence over the weekdays
+func (t *Table) entriesOn(date time.Time) []Entry {
+	d := date.Format(dateLayout)
+	for _, r := range t.Dates {
+		if d >= r.From && d <= r.To {
+			if r.Suppress {
+				return nil
+			}
+			return r.Entries
+		}
+	}
+	if entries, ok := t.Weekdays[date.Weekday()]; ok {
+		return entries
+	}
+	return t.Entries
+}
+
+// maxDays the maximum days to search the next slot
+const maxDays = 400
+
+// Next return the first slot after now, the actions of the entries
+// at the same time are merged. The zero slot is returned if no entry
+// matches in maxDays days.
+func (t *Table) Next(now time.Time) Slot {
+	now = now.In(t.Location)
+	year, month, day := now.Date()
+	from := now
+	for i := 0; i < maxDays; i++ {
+		start := time.Date(year, month, day+i, 0, 0, 0, 0, t.Location)
+		end := time.Date(year, month, day+i+1, 0, 0, 0, 0, t.Location)
+		if i != 0 {
+			from = start.Add(-time.Second)
+		}
+		if slot := t.next(t.entriesOn(start), from, end); !slot.Time.IsZero() {
+			return slot
+		}
+	}
+	return Slot{}
+}
+
+// DayOver report whether the day of now has no slot after now, and the
+// deadline of the adaptive polling, if any, has passed
+func (t *Table) DayOver(now time.Time) bool {
+	now = now.In(t.Location)
+	year, month, day := now.Date()
+	if a := t.Adaptive; a != nil && now.Before(time.Date(year, month, day, a.hour, a.minute, 0, 0, t.Location)) {
+		return false
+	}
+	next := t.Next(now)
+	if next.Time.IsZero() {
+		return true
+	}
+	y, m, d := next.Time.Date()
+	return y != year || m != month || d != day
+}
+
+// next return the first slot of the entries after from and before end,
+// the slots in the holidays are skipped
+func (t *Table) next(entries []Entry, from, end time.Time) Slot {
+	var slot Slot
+	for i := range entries {
+		spec := entries[i].spec
+		if spec == nil { // not created by UnmarshalJSON
+			var err error
+			if spec, err = Parse(entries[i].Cron); err != nil {
+				continue
+			}
+		}
+		at := spec.Next(from)
+		for e := t.Calendar.Holiday(at); e != nil && !at.IsZero() && at.Before(end); e = t.Calendar.Holiday(at) {
+			at = spec.Next(e.End.Add(-time.Second))
+		}
+		if at.IsZero() || !at.Before(end) {
+			continue
+		}
+		switch {
+		case slot.Time.IsZero() || at.Before(slot.Time):
+			slot = Slot{Time: at, Action: entries[i].Action}
+		case at.Equal(slot.Time):
+			slot.Action |= entries[i].Action
+		}
+	}
+	return slot
+}
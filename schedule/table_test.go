@@ -0,0 +1,159 @@
+package schedule
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+// nextTest a case of Table.Next
+type nextTest struct {
+	name   string
+	table  string
+	from   string // in the location of the table
+	want   string // RFC 3339, empty for the zero slot
+	action Action
+}
+
+func testTableNext(t *testing.T, tests []nextTest) {
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table := &Table{}
+			if err := json.Unmarshal([]byte(tt.table), table); err != nil {
+				t.Fatal(err)
+			}
+			from, err := time.ParseInLocation("2006-01-02 15:04:05", tt.from, table.Location)
+			if err != nil {
+				t.Fatal(err)
+			}
+			slot := table.Next(from)
+			if tt.want == "" {
+				if !slot.Time.IsZero() {
+					t.Errorf("Next = %v, want the zero slot", slot.Time)
+				}
+				return
+			}
+			if got := slot.Time.Format(time.RFC3339); got != tt.want || slot.Action != tt.action {
+				t.Errorf("Next = %s %s, want %s %s", got, slot.Action, tt.want, tt.action)
+			}
+		})
+	}
+}
+
+func TestTableNext(t *testing.T) {
+	testTableNext(t, []nextTest{
+		{
+			name:   "legacy entries",
+			table:  `[{"hour": 15, "minute": 0, "sendMail": true}, {"hour": 8, "minute": 30}]`,
+			from:   "2024-05-01 10:00:00",
+			want:   "2024-05-01T15:00:00+08:00",
+			action: ActionFetch | ActionNotify,
+		},
+		{
+			name:   "legacy entries next day",
+			table:  `[{"hour": 15, "minute": 0, "sendMail": true}, {"hour": 8, "minute": 30}]`,
+			from:   "2024-05-01 16:00:00",
+			want:   "2024-05-02T08:30:00+08:00",
+			action: ActionFetch,
+		},
+		{
+			name:   "merged actions",
+			table:  `[{"cron": "0 15 * * *", "action": "notify"}, {"cron": "0 15 * * *", "action": "summary"}]`,
+			from:   "2024-05-01 10:00:00",
+			want:   "2024-05-01T15:00:00+08:00",
+			action: ActionFetch | ActionNotify | ActionSummary,
+		},
+		{
+			name:   "time zone with dst gap",
+			table:  `{"timeZone": "America/New_York", "entries": [{"cron": "30 2 * * *"}]}`,
+			from:   "2024-03-09 12:00:00",
+			want:   "2024-03-11T02:30:00-04:00",
+			action: ActionFetch,
+		},
+	})
+}
+
+func TestTableOverrides(t *testing.T) {
+	testTableNext(t, []nextTest{
+		{
+			name: "weekday override",
+			table: `{"entries": [{"cron": "0 15 * * *"}],
+				"weekdays": {"saturday": [{"cron": "0 10 * * *", "action": "notify"}], "sunday": []}}`,
+			from:   "2024-05-03 16:00:00", // friday
+			want:   "2024-05-04T10:00:00+08:00",
+			action: ActionFetch | ActionNotify,
+		},
+		{
+			name: "empty weekday",
+			table: `{"entries": [{"cron": "0 15 * * *"}],
+				"weekdays": {"saturday": [{"cron": "0 10 * * *", "action": "notify"}], "sunday": []}}`,
+			from:   "2024-05-04 11:00:00", // saturday
+			want:   "2024-05-06T15:00:00+08:00",
+			action: ActionFetch,
+		},
+		{
+			name: "date override",
+			table: `{"entries": [{"cron": "0 15 * * *"}],
+				"weekdays": {"monday": [{"cron": "0 10 * * *"}]},
+				"dates": [{"from": "2024-05-01", "to": "2024-05-05", "suppress": true},
+					{"from": "2024-05-06", "entries": [{"cron": "0 9 * * *", "action": "summary"}]}]}`,
+			from:   "2024-04-30 16:00:00",
+			want:   "2024-05-06T09:00:00+08:00",
+			action: ActionFetch | ActionSummary,
+		},
+		{
+			name: "after date override",
+			table: `{"entries": [{"cron": "0 15 * * *"}],
+				"dates": [{"from": "2024-05-01", "to": "2024-05-05", "suppress": true}]}`,
+			from:   "2024-05-01 00:00:00",
+			want:   "2024-05-06T15:00:00+08:00",
+			action: ActionFetch,
+		},
+		{
+			name:  "suppressed forever",
+			table: `{"weekdays": {"monday": [{"cron": "0 9 * * *"}]}, "dates": [{"from": "2024-01-01", "to": "2099-12-31", "suppress": true}]}`,
+			from:  "2024-05-01 00:00:00",
+		},
+	})
+}
+
+func TestTableDayOver(t *testing.T) {
+	table := &Table{}
+	if err := json.Unmarshal([]byte(`{"entries": [{"cron": "0 15,21 * * *"}], "adaptive": {"deadline": "22:00", "rules": []}}`), table); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		now  string
+		want bool
+	}{
+		{"2024-05-01 10:00:00", false},
+		{"2024-05-01 21:00:00", false}, // before the deadline
+		{"2024-05-01 22:00:00", true},
+		{"2024-05-01 23:59:59", true},
+	}
+	for _, tt := range tests {
+		now, err := time.ParseInLocation("2006-01-02 15:04:05", tt.now, table.Location)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := table.DayOver(now); got != tt.want {
+			t.Errorf("DayOver(%s) = %v, want %v", tt.now, got, tt.want)
+		}
+	}
+}
+
+func TestTableDuplicateWeekday(t *testing.T) {
+	table := &Table{}
+	data := `{"weekdays": {"sat": [{"cron": "0 10 * * *"}], "Saturday": [{"cron": "0 15 * * *", "action": "notify"}]}}`
+	if err := json.Unmarshal([]byte(data), table); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(table.Weekdays[time.Saturday]); n != 2 {
+		t.Errorf("saturday has %d entries, want the 2 entries merged", n)
+	}
+	errs := table.Check()
+	if len(errs) != 1 || !errors.Is(errs[0], ErrDuplicateWeekday) {
+		t.Errorf("Check = %v, want %v", errs, ErrDuplicateWeekday)
+	}
+}
@@ -0,0 +1,140 @@
+// Package secret encrypt the passwords with a local key file
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	// ErrInsecurePermission the file is accessible by the group or others
+	ErrInsecurePermission = errors.New("secret: file is accessible by others, chmod 600 is required")
+	// ErrInvalidKey the content of the key file is invalid
+	ErrInvalidKey = errors.New("secret: invalid key")
+	// ErrInvalidCiphertext the encrypted text is invalid or encrypted by another key
+	ErrInvalidCiphertext = errors.New("secret: invalid ciphertext")
+)
+
+// Prefix the prefix of the encrypted text
+const Prefix = "enc:"
+
+// keySize the size of the AES-256 key
+const keySize = 32
+
+// ReadFile read the file which must not be accessible by the group and others
+func ReadFile(name string) ([]byte, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Mode().Perm()&0077 != 0 {
+		return nil, fmt.Errorf("%w: %s", ErrInsecurePermission, name)
+	}
+	return io.ReadAll(f)
+}
+
+// LoadKey read the key from the file, a new key is created if create
+// is set and the file does not exist
+func LoadKey(name string, create bool) ([]byte, error) {
+	data, err := ReadFile(name)
+	if errors.Is(err, os.ErrNotExist) && create {
+		return newKey(name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(key) != keySize {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidKey, name)
+	}
+	return key, nil
+}
+
+// newKey create a random key and write it to the file with mode 0600
+func newKey(name string) ([]byte, error) {
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, err
+	}
+	_, err = f.WriteString(base64.StdEncoding.EncodeToString(key) + "\n")
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(name)
+		return nil, err
+	}
+	return key, nil
+}
+
+// Encrypted report whether the text is encrypted by Encrypt
+func Encrypted(text string) bool {
+	return strings.HasPrefix(text, Prefix)
+}
+
+// Encrypt encrypt the text with AES-256-GCM, the result is Prefix + base64(nonce + ciphertext)
+func Encrypt(key []byte, text string) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize(), gcm.NonceSize()+len(text)+gcm.Overhead())
+	if _, err = rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return Prefix + base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, []byte(text), nil)), nil
+}
+
+// Decrypt decrypt the text encrypted by Encrypt
+func Decrypt(key []byte, text string) (string, error) {
+	if !Encrypted(text) {
+		return "", ErrInvalidCiphertext
+	}
+	data, err := base64.StdEncoding.DecodeString(text[len(Prefix):])
+	if err != nil {
+		return "", ErrInvalidCiphertext
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	if len(data) < gcm.NonceSize() {
+		return "", ErrInvalidCiphertext
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", ErrInvalidCiphertext
+	}
+	return string(plain), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != keySize {
+		return nil, ErrInvalidKey
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
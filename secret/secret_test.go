@@ -0,0 +1,102 @@
+package secret
+
+import (
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	key, err := LoadKey(filepath.Join(t.TempDir(), "keys", "key"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, text := range []string{"", "p@ss word", "密码"} {
+		enc, err := Encrypt(key, text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !Encrypted(enc) || strings.Contains(enc, text) && text != "" {
+			t.Errorf("Encrypt(%q) = %q", text, enc)
+		}
+		if again, _ := Encrypt(key, text); again == enc {
+			t.Errorf("Encrypt(%q) reused the nonce", text)
+		}
+		if got, err := Decrypt(key, enc); err != nil || got != text {
+			t.Errorf("Decrypt(Encrypt(%q)) = %q, %v", text, got, err)
+		}
+	}
+}
+
+func TestDecryptInvalid(t *testing.T) {
+	key := make([]byte, keySize)
+	enc, err := Encrypt(key, "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := make([]byte, keySize)
+	other[0] = 1
+	data, _ := base64.StdEncoding.DecodeString(enc[len(Prefix):])
+	data[len(data)-1] ^= 1
+	tampered := Prefix + base64.StdEncoding.EncodeToString(data)
+
+	tests := []struct {
+		name string
+		key  []byte
+		text string
+		want error
+	}{
+		{"wrong key", other, enc, ErrInvalidCiphertext},
+		{"tampered", key, tampered, ErrInvalidCiphertext},
+		{"truncated", key, Prefix + base64.StdEncoding.EncodeToString(data[:4]), ErrInvalidCiphertext},
+		{"not base64", key, Prefix + "!!", ErrInvalidCiphertext},
+		{"plain text", key, "password", ErrInvalidCiphertext},
+		{"short key", key[:16], enc, ErrInvalidKey},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got, err := Decrypt(test.key, test.text); !errors.Is(err, test.want) {
+				t.Errorf("Decrypt = %q, %v, want %v", got, err, test.want)
+			}
+		})
+	}
+}
+
+func TestLoadKey(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "key")
+	if _, err := LoadKey(name, false); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("LoadKey of the missing file: %v", err)
+	}
+	key, err := LoadKey(name, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(name); err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("the mode of the new key: %v, %v", info.Mode(), err)
+	}
+	if loaded, err := LoadKey(name, true); err != nil || string(loaded) != string(key) {
+		t.Errorf("LoadKey returned another key: %v", err)
+	}
+
+	if err = os.Chmod(name, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = LoadKey(name, false); !errors.Is(err, ErrInsecurePermission) {
+		t.Errorf("LoadKey of the 0644 file: %v, want %v", err, ErrInsecurePermission)
+	}
+	if _, err = ReadFile(name); !errors.Is(err, ErrInsecurePermission) {
+		t.Errorf("ReadFile of the 0644 file: %v, want %v", err, ErrInsecurePermission)
+	}
+
+	invalid := filepath.Join(dir, "invalid")
+	if err = os.WriteFile(invalid, []byte("c2hvcnQ=\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = LoadKey(invalid, false); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("LoadKey of the short key: %v, want %v", err, ErrInvalidKey)
+	}
+}
@@ -0,0 +1,315 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	client "report-stat/httpclient"
+	"report-stat/metrics"
+)
+
+// Server serve the frontend together with the latest form data from memory.
+// Each site is served under "/<site>/", the site "" is served under "/".
+type Server struct {
+	srv    *http.Server
+	static map[string]*file
+
+	mu    sync.RWMutex
+	sites map[string]map[string]*file // site -> name -> file
+
+	token   string
+	refresh RefreshFunc
+	status  StatusFunc
+	health  HealthFunc
+}
+
+// ErrNotFound the requested resource is not found, returned by RefreshFunc
+var ErrNotFound = errors.New("server: not found")
+
+// RefreshFunc run the task of the account immediately, all the accounts if name is empty,
+// the result is encoded as json in the response
+type RefreshFunc func(ctx context.Context, name string, notify bool) (interface{}, error)
+
+// StatusFunc return the status of the daemon, encoded as json in the response,
+// detail is set if the request carries the token of the refresh api
+type StatusFunc func(detail bool) interface{}
+
+// HealthFunc return nil if the daemon is healthy
+type HealthFunc func() error
+
+type file struct {
+	data        []byte
+	contentType string
+	etag        string
+	modTime     time.Time
+}
+
+// New create a server listen on addr, static is the file system of the frontend
+func New(addr string, static fs.FS) (*Server, error) {
+	s := &Server{
+		static: make(map[string]*file),
+		sites:  map[string]map[string]*file{"": {}},
+	}
+	err := fs.WalkDir(static, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(static, name)
+		if err != nil {
+			return err
+		}
+		s.static["/"+name] = newFile(data, mime.TypeByExtension(path.Ext(name)), time.Time{})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.srv = &http.Server{
+		Addr:              addr,
+		Handler:           s,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return s, nil
+}
+
+func newFile(data []byte, contentType string, modTime time.Time) *file {
+	sum := sha256.Sum256(data)
+	return &file{
+		data:        data,
+		contentType: contentType,
+		etag:        `"` + hex.EncodeToString(sum[:8]) + `"`,
+		modTime:     modTime,
+	}
+}
+
+// SetSites set the sites to serve, the data of the removed sites are dropped
+func (s *Server) SetSites(sites ...string) {
+	s.mu.Lock()
+	m := make(map[string]map[string]*file, len(sites))
+	for _, site := range sites {
+		if files, ok := s.sites[site]; ok {
+			m[site] = files
+		} else {
+			m[site] = map[string]*file{}
+		}
+	}
+	s.sites = m
+	s.mu.Unlock()
+}
+
+// Update replace the form data and images of the site with res
+func (s *Server) Update(site string, res *client.Result) {
+	files := make(map[string]*file, len(res.Images)+2)
+	files["/data.json"] = newFile(res.Data, "application/json", res.LastModified)
+	files["/image/status.json"] = newFile(res.Status, "application/json", res.LastModified)
+	for class, data := range res.Images {
+		files["/image/"+class+".webp"] = newFile(data, "image/webp", res.LastModified)
+	}
+	s.mu.Lock()
+	if _, ok := s.sites[site]; ok {
+		s.sites[site] = files
+	}
+	s.mu.Unlock()
+}
+
+// Set set the content of the file with name of the site, e.g. /image/stats.json
+func (s *Server) Set(site, name string, data []byte, contentType string, modTime time.Time) {
+	f := newFile(data, contentType, modTime)
+	s.mu.Lock()
+	if old, ok := s.sites[site]; ok {
+		files := make(map[string]*file, len(old)+1)
+		for k, v := range old {
+			files[k] = v
+		}
+		files[name] = f
+		s.sites[site] = files
+	}
+	s.mu.Unlock()
+}
+
+// HandleRefresh serve "POST /api/refresh?account=<name>&notify=1" with f,
+// the request must carry the header "Authorization: Bearer <token>".
+// The endpoint is disabled if token is empty.
+func (s *Server) HandleRefresh(token string, f RefreshFunc) {
+	s.mu.Lock()
+	s.token, s.refresh = token, f
+	s.mu.Unlock()
+}
+
+// HandleStatus serve "GET /api/status" with status and "GET /healthz" with health,
+// /healthz responds 200 if health returns nil, 503 with the error otherwise,
+// so the error must not carry the details
+func (s *Server) HandleStatus(status StatusFunc, health HealthFunc) {
+	s.mu.Lock()
+	s.status, s.health = status, health
+	s.mu.Unlock()
+}
+
+// serveStatus serve the status api and the health check
+func (s *Server) serveStatus(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	status, health, token := s.status, s.health, s.token
+	s.mu.RUnlock()
+	h := w.Header()
+	h.Set("Cache-Control", "no-store")
+	if r.URL.Path == "/healthz" {
+		if health == nil {
+			http.NotFound(w, r)
+		} else if err := health(); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		} else {
+			h.Set("Content-Type", "text/plain; charset=utf-8")
+			w.Write([]byte("ok\n"))
+		}
+		return
+	}
+	if status == nil {
+		http.NotFound(w, r)
+		return
+	}
+	data, err := json.Marshal(status(token != "" && authorized(r, token)))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// serveRefresh serve the refresh api
+func (s *Server) serveRefresh(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	token, f := s.token, s.refresh
+	s.mu.RUnlock()
+	if token == "" || f == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if !authorized(r, token) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	notify := false
+	if v := r.FormValue("notify"); v != "" {
+		var err error
+		if notify, err = strconv.ParseBool(v); err != nil {
+			http.Error(w, "invalid notify: "+v, http.StatusBadRequest)
+			return
+		}
+	}
+	v, err := f(r.Context(), r.FormValue("account"), notify)
+	if err != nil {
+		code := http.StatusInternalServerError
+		if errors.Is(err, ErrNotFound) {
+			code = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), code)
+		return
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h := w.Header()
+	h.Set("Content-Type", "application/json")
+	h.Set("Cache-Control", "no-store")
+	w.Write(data)
+}
+
+// authorized report whether the request carries the header "Authorization: Bearer <token>"
+func authorized(r *http.Request, token string) bool {
+	auth := r.Header.Get("Authorization")
+	return strings.HasPrefix(auth, "Bearer ") && subtle.ConstantTimeCompare([]byte(auth[7:]), []byte(token)) == 1
+}
+
+// ServeHTTP implement http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/api/refresh" {
+		s.serveRefresh(w, r)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	switch r.URL.Path {
+	case "/metrics":
+		metrics.Default.ServeHTTP(w, r)
+		return
+	case "/healthz", "/api/status":
+		s.serveStatus(w, r)
+		return
+	}
+	name := path.Clean("/" + r.URL.Path)
+	if strings.HasSuffix(r.URL.Path, "/") && name != "/" {
+		name += "/"
+	}
+	s.mu.RLock()
+	files, ok := s.sites[""]
+	if !ok { // the first element is the site
+		site := name[1:]
+		if i := strings.IndexByte(site, '/'); i >= 0 {
+			site, name = site[:i], site[i:]
+		} else {
+			name = ""
+		}
+		files, ok = s.sites[site]
+		if ok && name == "" { // e.g. /site -> /site/
+			s.mu.RUnlock()
+			http.Redirect(w, r, path.Base(r.URL.Path)+"/", http.StatusMovedPermanently)
+			return
+		}
+	}
+	s.mu.RUnlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if strings.HasSuffix(name, "/") {
+		name += "index.html"
+	}
+	f, ok := files[name]
+	if !ok {
+		if f, ok = s.static[name]; !ok {
+			http.NotFound(w, r)
+			return
+		}
+	}
+	h := w.Header()
+	h.Set("Content-Type", f.contentType)
+	h.Set("ETag", f.etag)
+	h.Set("Cache-Control", "no-cache") // always revalidate, the data changes at any time
+	http.ServeContent(w, r, name, f.modTime, bytes.NewReader(f.data))
+}
+
+// ListenAndServe listen on the address and serve the requests,
+// it always returns a non-nil error
+func (s *Server) ListenAndServe() error {
+	return s.srv.ListenAndServe()
+}
+
+// Shutdown gracefully shut down the server
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.srv.Shutdown(ctx)
+}
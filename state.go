@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	client "report-stat/httpclient"
+)
+
+// state the last slot run of each account, persisted to a file
+type state struct {
+	mu   sync.Mutex
+	path string
+	Last map[string]int64 `json:"last"` // account name -> unix timestamp of the slot
+}
+
+// slotState the state of the slots, nothing is persisted if path is empty
+var slotState = &state{}
+
+// loadState load the state from path, an empty state is returned if the file does not exist
+func loadState(path string) (*state, error) {
+	s := &state{path: path, Last: make(map[string]int64)}
+	if path == "" {
+		return s, nil
+	}
+	err := loadJson(s, path)
+	if os.IsNotExist(err) {
+		err = nil
+	}
+	if s.Last == nil {
+		s.Last = make(map[string]int64)
+	}
+	return s, err
+}
+
+// last return the time of the last slot run of the account
+func (s *state) last(name string) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if v, ok := s.Last[name]; ok {
+		return time.Unix(v, 0)
+	}
+	return time.Time{}
+}
+
+// done record the slot of the account and save the state,
+// the record is removed if slot is zero
+func (s *state) done(name string, slot time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Last == nil {
+		s.Last = make(map[string]int64)
+	}
+	if slot.IsZero() {
+		delete(s.Last, name)
+	} else {
+		s.Last[name] = slot.Unix()
+	}
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return client.WriteFile(s.path, data)
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"report-stat/history"
+	client "report-stat/httpclient"
+)
+
+// statsDays the window of stats.json
+const statsDays = 30
+
+type statsOutput struct {
+	From         string                `json:"from"`
+	To           string                `json:"to"`
+	LastModified int64                 `json:"lastModified"`
+	Students     []history.StudentStat `json:"students"`
+}
+
+// queryStats compute the statistics of the account in the last days,
+// today is not counted as missed until its slots are over
+func queryStats(s *history.Store, account *accountConfig, class string, days int) (*statsOutput, error) {
+	now := time.Now().In(timeZone)
+	q := history.Query{
+		From:  now.AddDate(0, 0, 1-days).Format("2006-01-02"),
+		To:    now.Format("2006-01-02"),
+		Wid:   account.Wid,
+		Key:   account.Key,
+		Class: class,
+	}
+	snaps, err := s.Query(q)
+	if err != nil {
+		return nil, err
+	}
+	open := q.To
+	if account.TimeTable != nil && account.TimeTable.DayOver(now) {
+		open = ""
+	}
+	return &statsOutput{
+		From:         q.From,
+		To:           q.To,
+		LastModified: now.Unix(),
+		Students:     history.Stats(snaps, open),
+	}, nil
+}
+
+// writeStats write stats.json next to status.json
+func writeStats(site string, account *accountConfig) error {
+	stats, err := queryStats(store, account, "", statsDays)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(stats)
+	if err != nil {
+		return err
+	}
+	if srv != nil {
+		srv.Set(site, "/image/stats.json", data, "application/json", time.Unix(stats.LastModified, 0))
+	}
+	if account.Out == "" {
+		return nil
+	}
+	return client.WriteFile(filepath.Join(account.Out, "stats.json"), data)
+}
+
+// statsCommand print the statistics of the students, return the exit code
+func statsCommand(flagSet *flag.FlagSet, args []string) int {
+	days := flagSet.Uint("days", 30, "set the window `days`")
+	class := flagSet.String("class", "", "only show the students of the `class`")
+	jsonOut := flagSet.Bool("json", false, "output in json format")
+	name := flagSet.String("name", "", "set the `name` of the account(default: the first account)")
+	flagSet.Parse(args)
+
+	if historyDir == "" || *days == 0 {
+		logger.Error("stats: history is disabled or days is zero")
+		return 2
+	}
+	accounts, err := loadSchedules()
+	if err != nil {
+		logger.Error(err.Error())
+		return 1
+	}
+	account, err := accounts.find(*name)
+	if err != nil {
+		logger.Error(err.Error())
+		return 1
+	}
+	s, err := history.Open(historyDir, 0)
+	if err != nil {
+		logger.Error(err.Error())
+		return 1
+	}
+	stats, err := queryStats(s, account, *class, int(*days))
+	if err != nil {
+		logger.Error(err.Error())
+		return 1
+	}
+
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "\t")
+		enc.SetEscapeHTML(false)
+		if err = enc.Encode(stats); err != nil {
+			logger.Error(err.Error())
+			return 1
+		}
+		return 0
+	}
+	fmt.Printf("%s ~ %s\n", stats.From, stats.To)
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprint(w, "学号\t姓名\t班级\t缺报天数\t连续缺报\t通常填报时间\n")
+	for _, v := range stats.Students {
+		reportTime := v.ReportTime
+		if reportTime == "" {
+			reportTime = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%s\n", v.ID, v.Name, v.Class, v.DaysMissed, v.Streak, reportTime)
+	}
+	if err = w.Flush(); err != nil {
+		logger.Error(err.Error())
+		return 1
+	}
+	return 0
+}
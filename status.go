@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	client "report-stat/httpclient"
+	"report-stat/schedule"
+)
+
+// statusCommand print the next slot of each account and the holiday
+// the scheduler is paused by, return the exit code
+func statusCommand(flagSet *flag.FlagSet, args []string) int {
+	flagSet.Parse(args)
+
+	accounts, err := loadSchedules()
+	if err != nil {
+		logger.Error(err.Error())
+		return 1
+	}
+	now := time.Now()
+	for _, account := range accounts {
+		table := account.TimeTable
+		fmt.Printf("[%s]\n", account.Name)
+		slot := table.Next(now)
+		if e := table.Calendar.Holiday(now); e != nil {
+			fmt.Printf("  paused:    %s\n", e)
+		} else if e = table.Calendar.Overlap(now, slot.Time); e != nil && !slot.Time.IsZero() {
+			fmt.Printf("  upcoming:  %s\n", e)
+		}
+		if slot.Time.IsZero() {
+			fmt.Print("  next slot: none\n")
+			continue
+		}
+		fmt.Printf("  next slot: %s %s\n", slot.Time.In(table.Location).Format("2006-01-02 15:04:05"), slot.Action)
+	}
+	return 0
+}
+
+// taskStatus the state of the tasks of a worker, guarded by worker.mu
+type taskStatus struct {
+	attempt     uint // attempt of the running task, 0 if idle
+	lastRun     time.Time
+	lastSuccess time.Time
+	lastErr     error
+	lastErrTime time.Time
+	remains     int
+	next        schedule.Slot
+}
+
+// finish record the result of a task
+func (w *worker) finish(res *client.Result, err error) {
+	now := time.Now()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.status.attempt = 0
+	w.status.lastRun = now
+	switch {
+	case err == nil:
+		w.status.lastSuccess = now
+		w.status.remains = remaining(res)
+	case err == ErrWorkerStopped, errors.Is(err, context.Canceled):
+	default:
+		w.status.lastErr, w.status.lastErrTime = err, now
+	}
+}
+
+// taskError the error of the last failed task
+type taskError struct {
+	Time     int64  `json:"time"`
+	Category string `json:"category"`          // see errorCategory
+	Message  string `json:"message,omitempty"` // only with the token of the refresh api
+}
+
+// slotStatus the next slot of an account
+type slotStatus struct {
+	Time   int64           `json:"time"`
+	Action schedule.Action `json:"action"`
+}
+
+// accountStatus the status of an account reported by /api/status,
+// the times are unix seconds and omitted if never happened
+type accountStatus struct {
+	Name        string      `json:"name"`
+	Attempt     uint        `json:"attempt"` // attempt of the running task, 0 if idle
+	LastRun     int64       `json:"lastRun,omitempty"`
+	LastSuccess int64       `json:"lastSuccess,omitempty"`
+	LastError   *taskError  `json:"lastError,omitempty"`
+	Remains     int         `json:"remains"` // students remaining at the last success, -1 if unknown
+	Next        *slotStatus `json:"next,omitempty"`
+}
+
+// daemonStatus the status of the daemon reported by /api/status
+type daemonStatus struct {
+	Started       int64           `json:"started"`
+	ConfigVersion string          `json:"configVersion"` // hash of the config files
+	ConfigLoaded  int64           `json:"configLoaded"`
+	Accounts      []accountStatus `json:"accounts"`
+}
+
+// startTime the time the process started
+var startTime = time.Now()
+
+// snapshot return the status of the worker, the error message is included if detail is set
+func (w *worker) snapshot(detail bool) accountStatus {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	st := &w.status
+	v := accountStatus{
+		Name:        w.Name,
+		Attempt:     st.attempt,
+		LastRun:     unix(st.lastRun),
+		LastSuccess: unix(st.lastSuccess),
+		Remains:     -1,
+	}
+	if !st.lastSuccess.IsZero() {
+		v.Remains = st.remains
+	}
+	if st.lastErr != nil {
+		v.LastError = &taskError{
+			Time:     st.lastErrTime.Unix(),
+			Category: errorCategory(st.lastErr),
+		}
+		if detail {
+			v.LastError.Message = st.lastErr.Error()
+		}
+	}
+	if !st.next.Time.IsZero() {
+		v.Next = &slotStatus{Time: st.next.Time.Unix(), Action: st.next.Action}
+	}
+	return v
+}
+
+// getStatus return the status of the running app for /api/status,
+// the error messages may contain the account names and the hosts, they are
+// included only if detail is set
+func getStatus(detail bool) interface{} {
+	runningMu.RLock()
+	defer runningMu.RUnlock()
+	v := &daemonStatus{
+		Started:  startTime.Unix(),
+		Accounts: make([]accountStatus, len(running)),
+	}
+	if runningConfig != nil {
+		v.ConfigVersion = runningConfig.version
+		v.ConfigLoaded = runningConfig.loaded.Unix()
+	}
+	for i, w := range running {
+		v.Accounts[i] = w.snapshot(detail)
+	}
+	return v
+}
+
+var (
+	// ErrTaskFailed the last task of an account failed, reported by /healthz
+	ErrTaskFailed = errors.New("health: last task failed")
+	// ErrStale no task of an account succeeded within staleAfter, reported by /healthz
+	ErrStale = errors.New("health: no successful task")
+)
+
+// checkHealth report whether the workers are running and the tasks succeed for /healthz.
+// An account is unhealthy if its last task failed, or no task succeeded within
+// staleAfter since the last success or the start of the process.
+// The error carries only the category, /healthz is not authorized.
+func checkHealth() error {
+	runningMu.RLock()
+	defer runningMu.RUnlock()
+	now := time.Now()
+	err := ErrWorkerStopped
+	for _, w := range running {
+		w.mu.Lock()
+		stopped, st := w.stopped, w.status
+		w.mu.Unlock()
+		if stopped {
+			continue
+		}
+		err = nil
+		if st.lastErr != nil && st.lastErrTime.After(st.lastSuccess) {
+			return fmt.Errorf("%w: %s", ErrTaskFailed, errorCategory(st.lastErr))
+		}
+		since := st.lastSuccess
+		if since.IsZero() {
+			since = startTime
+		}
+		if staleAfter > 0 && now.Sub(since) > staleAfter {
+			return ErrStale
+		}
+	}
+	return err
+}
+
+// unix return the unix seconds of t, 0 if t is zero
+func unix(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
@@ -0,0 +1,566 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"report-stat/history"
+	client "report-stat/httpclient"
+	"report-stat/logging"
+	"report-stat/notify"
+	"report-stat/schedule"
+	"report-stat/secret"
+
+	"github.com/yin1999/healthreport/utils/email"
+)
+
+// worker fetch the form data of an account on schedule
+type worker struct {
+	*accountConfig
+	site      string // path prefix on the server
+	logger    *logging.Logger
+	notifiers []notify.Notifier
+
+	remains     int           // students remaining at the last successful fetch, -1 if unknown
+	lastRun     time.Time     // time of the last task finished
+	interrupted schedule.Slot // the slot stopped while waiting to retry, resumed by the next worker
+
+	mu      sync.Mutex
+	status  taskStatus    // reported by /api/status
+	refresh *refresh      // the pending or running refresh
+	stopped bool          // the worker is not running, the refresh is rejected
+	trigger chan struct{} // notify the worker of the refresh
+	quit    <-chan struct{}
+}
+
+func newWorker(account *accountConfig, mail *email.Config, multiple bool) (*worker, error) {
+	w := &worker{
+		accountConfig: account,
+		logger:        logger.With("account", account.Name),
+		remains:       -1,
+		trigger:       make(chan struct{}, 1),
+	}
+	if multiple {
+		w.site = account.Name
+	}
+	for i := range account.Notifiers {
+		n, err := notify.New(&account.Notifiers[i], mail)
+		if err != nil {
+			return nil, fmt.Errorf("notifier %d: %w", i, err)
+		}
+		w.notifiers = append(w.notifiers, n)
+	}
+	if len(account.Notifiers) == 0 && mail != nil { // default: send email to the receivers in email config
+		w.notifiers = append(w.notifiers, &notify.Email{Config: mail, Plain: true})
+	}
+	return w, nil
+}
+
+// link return the link of the web page
+func (w *worker) link() string {
+	link := "https://" + w.Domain + "/report-stat/"
+	if w.site != "" {
+		link += url.PathEscape(w.site) + "/"
+	}
+	return link
+}
+
+// clockJumpThreshold the minimum difference between the wall clock and the
+// monotonic clock regarded as a clock jump, e.g. the system is resumed from sleep
+const clockJumpThreshold = time.Minute
+
+// run run the task on schedule until quit is closed or ctx is done, the running task
+// is finished before quit, while ctx cancels it.
+// The failure of a task is logged and does not affect other workers.
+// The slots missed in the catch-up window (e.g. when the process is restarted
+// or the system is resumed from sleep) are run once.
+func (w *worker) run(ctx context.Context, quit <-chan struct{}) {
+	w.quit = quit
+	defer w.stop()
+	if slot := w.interrupted; !slot.Time.IsZero() {
+		w.interrupted = schedule.Slot{}
+		w.logger.Info("Resume the interrupted slot", "slot", formatSlot(slot))
+		w.runSlot(ctx, slot)
+		select {
+		case <-quit: // interrupted again
+			return
+		default:
+		}
+	}
+	now := time.Now()
+	if missed, ok := w.missed(now.Add(-catchUpWindow), now); ok {
+		w.logger.Info("Catch up the missed slot", "slot", formatSlot(missed))
+		w.runSlot(ctx, missed)
+		now = time.Now()
+	}
+	timer := time.NewTimer(time.Hour)
+	slot := w.reset(timer, now)
+	ticker := time.NewTicker(clockJumpThreshold)
+	defer ticker.Stop()
+	prev := now
+	for {
+		select {
+		case <-timer.C:
+			if late := time.Since(slot.Time); late > clockJumpThreshold && late > catchUpWindow {
+				w.logger.Warn("Skip the slot", "slot", formatSlot(slot), "late", late.Round(time.Second))
+			} else {
+				w.runSlot(ctx, slot)
+			}
+			now = time.Now()
+			if now.Before(slot.Time) { // the wall clock may be behind the timer
+				now = slot.Time
+			}
+		case <-ticker.C:
+			now = time.Now()
+			jump := now.Round(0).Sub(prev.Round(0)) - now.Sub(prev) // wall clock - monotonic clock
+			prev = now
+			if jump < clockJumpThreshold && jump > -clockJumpThreshold {
+				continue
+			}
+			w.logger.Warn("Clock jumped, reschedule", "jump", jump.Round(time.Second))
+			from := now.Add(-jump)
+			if from.Before(now.Add(-catchUpWindow)) {
+				from = now.Add(-catchUpWindow)
+			}
+			if missed, ok := w.missed(from, now); ok {
+				w.logger.Info("Catch up the missed slot", "slot", formatSlot(missed))
+				w.runSlot(ctx, missed)
+				now = time.Now()
+			}
+		case <-w.trigger:
+			w.runRefresh(ctx)
+			now = time.Now()
+		case <-quit:
+			timer.Stop()
+			return
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+		prev = time.Now()
+		if last := slotState.last(w.Name); now.Before(last) { // the clock may jump backward
+			now = last
+		}
+		slot = w.reset(timer, now)
+	}
+}
+
+// reset reset the timer to the next slot after now,
+// the timer is stopped if there is no slot in the time table
+func (w *worker) reset(timer *time.Timer, now time.Time) (slot schedule.Slot) {
+	if !timer.Stop() {
+		select { // drain the channel
+		case <-timer.C:
+		default:
+		}
+	}
+	slot = w.next(now)
+	w.mu.Lock()
+	w.status.next = slot
+	w.mu.Unlock()
+	if slot.Time.IsZero() {
+		w.logger.Warn("No slot in the time table")
+		return
+	}
+	w.logNext(now, slot)
+	timer.Reset(time.Until(slot.Time))
+	return
+}
+
+// next return the next slot after now, including the adaptive fetches
+func (w *worker) next(now time.Time) schedule.Slot {
+	return nextSlot(w.TimeTable, now, w.remains, w.lastRun)
+}
+
+// nextSlot return the first slot of the time table or the adaptive fetch after now
+func nextSlot(table *schedule.Table, now time.Time, remains int, lastFetch time.Time) schedule.Slot {
+	slot := table.Next(now)
+	if t := table.NextAdaptive(now, remains, lastFetch); !t.IsZero() && (slot.Time.IsZero() || t.Before(slot.Time)) {
+		slot = schedule.Slot{Time: t, Action: schedule.ActionFetch}
+	}
+	return slot
+}
+
+// remaining return the number of students remaining in the configured classes,
+// all the students remaining if no class is configured
+func remaining(res *client.Result) int {
+	if len(res.Remains) == 0 {
+		return res.Total
+	}
+	if n, ok := res.Remains["全部"]; ok {
+		return n
+	}
+	n := 0
+	for _, v := range res.Remains {
+		n += v
+	}
+	return n
+}
+
+// missed return the slots after from and not after now which have not been run,
+// the actions are merged into the last slot
+func (w *worker) missed(from, now time.Time) (slot schedule.Slot, ok bool) {
+	if last := slotState.last(w.Name); from.Before(last) {
+		from = last
+	}
+	for s := w.TimeTable.Next(from); !s.Time.IsZero() && !s.Time.After(now); s = w.TimeTable.Next(s.Time) {
+		slot.Time = s.Time
+		slot.Action |= s.Action
+		ok = true
+	}
+	return
+}
+
+// runSlot run the task of the slot, the slot is recorded before the task
+// runs, so that it is never run twice after restart.
+// If the worker is stopped while waiting to retry, the record is reverted and
+// the slot is kept in interrupted, so that it is resumed after reload or caught
+// up after restart.
+func (w *worker) runSlot(ctx context.Context, slot schedule.Slot) {
+	prev := slotState.last(w.Name)
+	if err := slotState.done(w.Name, slot.Time); err != nil {
+		w.logger.Error("Save state failed", "error", err)
+	}
+	switch _, err := w.task(ctx, slot, maxAttempts); err {
+	case ErrWorkerStopped:
+		w.interrupted = slot
+		if err := slotState.done(w.Name, prev); err != nil {
+			w.logger.Error("Save state failed", "error", err)
+		}
+	case nil, context.Canceled:
+	default:
+		w.notify(ctx, w.logger, w.failure(err))
+	}
+	w.lastRun = time.Now()
+}
+
+// logNext log the next slot and the holiday the scheduler is paused by
+func (w *worker) logNext(now time.Time, slot schedule.Slot) {
+	if e := w.TimeTable.Calendar.Overlap(now, slot.Time); e != nil {
+		w.logger.Info("Scheduler paused by holiday", "holiday", e)
+	}
+	w.logger.Info("Next slot", "slot", formatSlot(slot))
+}
+
+// formatSlot format the time and the action of the slot for the logs
+func formatSlot(slot schedule.Slot) string {
+	return slot.Time.Format("2006-01-02 15:04:05") + " " + slot.Action.String()
+}
+
+var ErrMaximumAttemptsExceeded = errors.New("serve: maximum attempts exceeded")
+
+// task fetch the form data and run the actions of the slot, retry at most attempts times
+// with the retry policy, the non-retryable errors fail the task immediately.
+// The logs of the task carry the run id, and the logs of each attempt the attempt number.
+func (w *worker) task(ctx context.Context, slot schedule.Slot, attempts uint) (res *client.Result, err error) {
+	logger := w.logger.With("run", logging.NewID(), "slot", formatSlot(slot))
+	account := &w.Account
+	policy := retry
+	start := time.Now()
+	logger.Info("Start get form routine")
+	defer func() {
+		w.finish(res, err)
+		switch {
+		case err == nil:
+			logger.Info("Task finished", "duration", since(start))
+		case err == ErrWorkerStopped:
+			logger.Info("Retry interrupted, the worker is stopped", "duration", since(start))
+		case errors.Is(err, context.Canceled):
+			logger.Info("Task canceled", "duration", since(start))
+		default:
+			logger.Error("Task failed", "error", err, "category", errorCategory(err), "duration", since(start))
+		}
+	}()
+	plain, err := w.plain()
+	if err != nil {
+		return nil, err
+	}
+
+	var timer *time.Timer
+	for count := uint(1); true; count++ {
+		logger := logger.With("attempt", count)
+		logger.Debug("Start getting form")
+		w.mu.Lock()
+		w.status.attempt = count
+		w.mu.Unlock()
+		begin := time.Now()
+		fetchAttempts.Inc(w.Name)
+		c, cc := context.WithTimeout(ctx, time.Duration(policy.Timeout))
+		res, err = client.GetFormData(c, plain)
+		cc()
+		fetchDuration.Observe(time.Since(begin).Seconds(), w.Name)
+		switch err {
+		case nil:
+			w.remains = remaining(res)
+			remainingStudents.DeletePrefix(w.Name)
+			for class, n := range res.Remains {
+				remainingStudents.Set(float64(n), w.Name, class)
+			}
+			lastSuccess.Set(float64(res.LastModified.Unix()), w.Name)
+			logger.Info("Get form finished", "duration", since(begin), "total", res.Total, "remains", w.remains)
+			if srv != nil {
+				srv.Update(w.site, res)
+			}
+			if store != nil {
+				if err := store.Append(&history.Snapshot{
+					Date:     res.Date,
+					Slot:     slot.String(),
+					Wid:      account.Wid,
+					Key:      account.Key,
+					Time:     res.LastModified.Unix(),
+					Students: res.Students,
+				}); err != nil {
+					logger.Error("Store history failed", "error", err)
+				} else if err := writeStats(w.site, w.accountConfig); err != nil {
+					logger.Error("Write stats failed", "error", err)
+				}
+			}
+			if !res.Empty() && slot.Action.Has(schedule.ActionNotify) {
+				w.notify(ctx, logger, w.reminder(res))
+				w.notifyClasses(ctx, logger, res)
+			}
+			if slot.Action.Has(schedule.ActionSummary) {
+				w.notify(ctx, logger, w.summary(res))
+			}
+			return
+		case context.Canceled:
+			return nil, err
+		}
+		category := errorCategory(err)
+		fetchFailures.Inc(w.Name, category)
+		if !retryable(err) {
+			logger.Warn("Get form failed, not retryable", "error", err, "category", category, "duration", since(begin))
+			return nil, fmt.Errorf("non-retryable error at attempt %d: %w", count, err)
+		}
+		if count >= attempts {
+			logger.Warn("Get form failed", "error", err, "category", category, "duration", since(begin))
+			break
+		}
+		delay := policy.delay(count)
+		if policy.MaxElapsed > 0 && time.Since(start)+delay > time.Duration(policy.MaxElapsed) {
+			logger.Warn("Get form failed, maximum elapsed time reached", "error", err, "category", category, "duration", since(begin))
+			return nil, fmt.Errorf("maximum elapsed time: %v reached after %d attempts with error: %w", time.Duration(policy.MaxElapsed), count, err)
+		}
+		logger.Warn("Get form failed, retry later", "error", err, "category", category, "duration", since(begin), "retryAfter", delay.Round(time.Second))
+
+		if timer == nil {
+			timer = time.NewTimer(delay)
+		} else {
+			timer.Reset(delay)
+		}
+	wait:
+		for {
+			select {
+			case <-timer.C:
+				break wait
+			case <-w.trigger: // the refresh runs at once instead of after the retries
+				w.runRefresh(ctx)
+				w.mu.Lock()
+				w.status.attempt = count
+				w.mu.Unlock()
+			case <-w.quit:
+				timer.Stop()
+				return nil, ErrWorkerStopped
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, ctx.Err()
+			}
+		}
+	}
+	return nil, fmt.Errorf("maximum attempts: %d reached with error: %w", attempts, err)
+}
+
+// since return the duration since t for the logs
+func since(t time.Time) time.Duration {
+	return time.Since(t).Round(time.Millisecond)
+}
+
+// errorCategory classify the error of a task for the logs
+func errorCategory(err error) string {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, client.ErrLoginPageChanged):
+		return "parse"
+	case errors.Is(err, client.ErrCouldNotLogin):
+		return "login"
+	case errors.Is(err, client.ErrCouldNotGetFormSession):
+		return "session"
+	case errors.Is(err, client.ErrCannotParseData): // e.g. the schema of the form is changed
+		return "parse"
+	case errors.Is(err, secret.ErrInvalidCiphertext), errors.Is(err, secret.ErrInvalidKey), errors.Is(err, secret.ErrInsecurePermission):
+		return "credential"
+	case errors.Is(err, os.ErrNotExist), errors.Is(err, os.ErrPermission): // e.g. the password file
+		return "config"
+	case errors.Is(err, context.Canceled), errors.Is(err, ErrWorkerStopped):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.As(err, &netErr):
+		if netErr.Timeout() {
+			return "timeout"
+		}
+		return "network"
+	}
+	return "upstream"
+}
+
+// failure return the message of the failed task
+func (w *worker) failure(err error) *notify.Message {
+	text := fmt.Sprintf("账户: %s 获取表单失败 err: %s", w.Name, err.Error())
+	if hint := loginHint(err); hint != "" {
+		text += "\n" + hint
+	}
+	return &notify.Message{
+		Title: "获取表单失败提示",
+		Text:  text,
+	}
+}
+
+// loginHint return the action to take for the login error, empty if unknown
+func loginHint(err error) string {
+	switch {
+	case errors.Is(err, client.ErrWrongPassword):
+		return "用户名或密码错误，请更新配置中的密码"
+	case errors.Is(err, client.ErrAccountLocked):
+		return "账号已被锁定，请等待解锁或联系管理员"
+	case errors.Is(err, client.ErrCaptchaRequired):
+		return "登录需要验证码，请先在浏览器中成功登录一次"
+	case errors.Is(err, client.ErrPasswordExpired):
+		return "密码已过期，请修改密码并更新配置"
+	case errors.Is(err, client.ErrLoginPageChanged):
+		return "登录页面格式已变化，请更新程序"
+	}
+	return ""
+}
+
+// reminder return the message of the students who have not reported
+func (w *worker) reminder(res *client.Result) *notify.Message {
+	text := &strings.Builder{}
+	fmt.Fprintf(text, "截至 %s 共 %d 人未填报", res.LastModified.In(w.TimeTable.Location).Format("15:04"), res.Total)
+	for _, class := range w.Class {
+		if n := res.Remains[class]; n != 0 && class != "全部" {
+			fmt.Fprintf(text, "\n- %s: %d 人", class, n)
+		}
+	}
+	msg := &notify.Message{
+		Title: "未填报名单提醒",
+		Text:  text.String(),
+		Link:  w.link(),
+	}
+	if img, err := res.PNG("全部"); err == nil {
+		msg.Image = img
+	}
+	return msg
+}
+
+// chronicStreak the minimum consecutive missed days listed in the summary
+const chronicStreak = 2
+
+// summary return the summary message of the remaining students
+// and the students missed in consecutive days
+func (w *worker) summary(res *client.Result) *notify.Message {
+	text := &strings.Builder{}
+	fmt.Fprintf(text, "%s 截至 %s 共 %d 人未填报", res.Date, res.LastModified.In(w.TimeTable.Location).Format("15:04"), res.Total)
+	for _, class := range w.Class {
+		if class != "全部" {
+			fmt.Fprintf(text, "\n- %s: %d 人", class, res.Remains[class])
+		}
+	}
+	if store != nil {
+		stats, err := queryStats(store, w.accountConfig, "", statsDays)
+		if err != nil {
+			w.logger.Error("Query stats failed", "error", err)
+		} else {
+			first := true
+			for _, v := range stats.Students {
+				if v.Streak < chronicStreak {
+					continue
+				}
+				if first {
+					fmt.Fprintf(text, "\n\n连续 %d 天及以上未填报:", chronicStreak)
+					first = false
+				}
+				fmt.Fprintf(text, "\n- %s %s(%s): 连续 %d 天, 近 %d 天共 %d 天", v.ID, v.Name, v.Class, v.Streak, statsDays, v.DaysMissed)
+			}
+		}
+	}
+	return &notify.Message{
+		Title: "每日填报汇总",
+		Text:  text.String(),
+		Link:  w.link(),
+	}
+}
+
+// notify send the message by all the notifiers, the errors are logged to logger
+func (w *worker) notify(ctx context.Context, logger *logging.Logger, msg *notify.Message) {
+	for _, n := range w.notifiers {
+		if err := n.Notify(ctx, msg); err != nil {
+			notificationsFailed.Inc(w.Name)
+			logger.Error("Send message failed", "title", msg.Title, "error", err)
+		} else {
+			notificationsSent.Inc(w.Name)
+			logger.Info("Send message success", "title", msg.Title)
+		}
+	}
+}
+
+// notifyClasses send each class's contacts the students of their own class,
+// the classes all reported are skipped
+func (w *worker) notifyClasses(ctx context.Context, logger *logging.Logger, res *client.Result) {
+	if len(w.Contacts) == 0 {
+		return
+	}
+	if emailCfg == nil {
+		logger.Warn("Email is not enabled, skip the class reminders")
+		return
+	}
+	students := make(map[string][]client.Student)
+	for _, student := range res.Students {
+		students[student.Class] = append(students[student.Class], student)
+	}
+	for class := range w.Contacts {
+		list := students[class]
+		if class == "全部" {
+			list = res.Students
+		}
+		to := w.Contacts.emails(class)
+		if len(list) == 0 || len(to) == 0 {
+			continue
+		}
+		msg := &notify.Message{
+			Title: class + " 未填报名单提醒",
+			HTML:  classTable(class, list, w.link()),
+		}
+		if img, err := res.PNG(class); err == nil {
+			msg.Image = img
+		}
+		n := &notify.Email{Config: emailCfg, To: to}
+		if err := n.Notify(ctx, msg); err != nil {
+			notificationsFailed.Inc(w.Name)
+			logger.Error("Send message failed", "class", class, "error", err)
+		} else {
+			notificationsSent.Inc(w.Name)
+			logger.Info("Send message success", "class", class)
+		}
+	}
+}
+
+// classTable return the students as a html table
+func classTable(class string, students []client.Student, link string) string {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "<p>%s 共 %d 人未填报</p>", html.EscapeString(class), len(students))
+	b.WriteString(`<table border="1" cellspacing="0" cellpadding="4"><tr><th>学号</th><th>姓名</th></tr>`)
+	for _, student := range students {
+		fmt.Fprintf(b, "<tr><td>%s</td><td>%s</td></tr>", html.EscapeString(student.ID), html.EscapeString(student.Name))
+	}
+	fmt.Fprintf(b, `</table><p><a href="%s">查看全部名单</a></p>`, html.EscapeString(link))
+	return b.String()
+}